@@ -1,33 +1,113 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/hblanks/speakwrite/internal/accesslog"
+	"github.com/hblanks/speakwrite/internal/compress"
+	"github.com/hblanks/speakwrite/internal/content"
+	"github.com/hblanks/speakwrite/internal/images"
+	"github.com/hblanks/speakwrite/internal/linkcheck"
 	"github.com/hblanks/speakwrite/internal/render"
+	"github.com/hblanks/speakwrite/internal/search"
 	"github.com/hblanks/speakwrite/internal/web"
 )
 
 func main() {
 	flag.Usage = func() {
 		out := flag.CommandLine.Output()
-		fmt.Fprintf(out, "Usage: %s [serve|render]\n", os.Args[0])
+		fmt.Fprintf(out, "Usage: %s [serve|render|linkcheck]\n", os.Args[0])
+		fmt.Fprintf(out, "       %s check\n", os.Args[0])
+		fmt.Fprintf(out, "       %s new post TITLE [--series NAME] [--tags A,B] [--date YYYY-MM-DD]\n", os.Args[0])
+		fmt.Fprintf(out, "       %s highlight-css STYLE\n", os.Args[0])
 		fmt.Fprintf(out,
-			`Required environment variables:
+			`Commands:
+	serve			Run the dev server
+	render			Render the site to OUTPUT_DIR, then check its links
+	linkcheck		Check the site for broken internal links
+	check			Report all problems in CONTENT_DIR; exits nonzero
+			if any are found (needs only CONTENT_DIR)
+	new post		Create a new post directory in CONTENT_DIR, named for
+			TITLE and dated today (needs only CONTENT_DIR)
+	highlight-css	Write CSS for code highlight STYLE to
+			THEME_DIR/static/highlight.css (needs only THEME_DIR)
+
+Required environment variables:
 	CONTENT_DIR		= Path to site content/ dir
 	THEME_DIR		= Path to theme/ dir
 	PUBLIC_URL		= Public URL for the site
 
 Optional environment variables:
 	LISTEN_ADDR		= For "serve": listen address (default: localhost:8080)
+	READ_TIMEOUT	= For "serve": max time to read a request (default: 10s)
+	WRITE_TIMEOUT	= For "serve": max time to write a response (default: 30s)
+	IDLE_TIMEOUT	= For "serve": max time to keep an idle connection
+			  open (default: 120s)
+	SHUTDOWN_TIMEOUT	= For "serve": max time to drain connections on
+			  SIGTERM or SIGINT (default: 15s)
+	LOG_FORMAT		= For "serve": access log format: "common", "json" or
+			  "none" (default: common)
+	LOG_LEVEL		= "debug" to log per-request detail (default: info)
+	METRICS			= For "serve": if "1", serve Prometheus metrics at /metrics
 	OUTPUT_DIR      = For "render": where to write output (default: speakwrite-out)
+	PRECOMPRESS		= For "render": if "1", also write .gz and .br copies
+			  of text files
+	PAGE_SIZE		= Posts per page of the home page (default: 10)
+	RELATED_POSTS	= Related posts to find for each post, or 0 for none
+			  (default: 3)
+	CITATION_STYLE	= How to cite references: "numeric" or "author-date",
+			  unless a post's metadata.json says otherwise
+			  (default: numeric)
+	MATHML			= If "1", render $math$ as MathML rather than leave it
+			  for MathJax
+	SIDENOTES		= If "1", also render footnotes as sidenotes, unless a
+			  post's metadata.json says otherwise
+	TYPOGRAPHY		= Typographic transforms for posts and titles, as a
+			  list of "quotes", "dashes", "ellipses", "units" and
+			  "widows", or "all" (default: the markdown renderer's
+			  smartypants)
+	SEARCH_INDEX	= Format of /search-index.json: "full" (each post's
+			  text) or "inverted" (words to posts) (default: full)
+	UPDATED_FROM	= Where to find when posts were last updated, if
+			  not in metadata.json: "git" or "mtime" (default: neither)
+	IMAGE_WIDTHS	= Widths at which to resize post images, e.g.
+			  "480,960,1600" (default: none; images are served as is)
+	IMAGE_SIZES		= sizes attribute for resized images (default: 100vw)
+	IMAGE_CACHE_DIR	= Where to cache resized images
+			  (default: $XDG_CACHE_HOME/speakwrite/images)
 `)
 	}
 	flag.Parse()
 
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "highlight-css":
+		writeHighlightCSS(os.Getenv("THEME_DIR"), args[1:])
+		return
+	case "check":
+		check(os.Getenv("CONTENT_DIR"))
+		return
+	case "new":
+		newPost(os.Getenv("CONTENT_DIR"), args[1:])
+		return
+	}
+
 	contentDir := os.Getenv("CONTENT_DIR")
 	themeDir := os.Getenv("THEME_DIR")
 	publicURL := os.Getenv("PUBLIC_URL")
@@ -36,13 +116,62 @@ Optional environment variables:
 		os.Exit(1)
 	}
 
-	args := flag.Args()
-	if len(args) == 0 {
+	opts := &web.Options{
+		Content: content.Options{
+			UpdatedFrom:   os.Getenv("UPDATED_FROM"),
+			Sidenotes:     os.Getenv("SIDENOTES") == "1",
+			CitationStyle: os.Getenv("CITATION_STYLE"),
+			MathML:        os.Getenv("MATHML") == "1",
+		},
+		Images: images.Options{
+			Sizes:    os.Getenv("IMAGE_SIZES"),
+			CacheDir: os.Getenv("IMAGE_CACHE_DIR"),
+		},
+		Debug:       os.Getenv("LOG_LEVEL") == "debug",
+		Metrics:     args[0] == "serve" && os.Getenv("METRICS") == "1",
+		SearchIndex: os.Getenv("SEARCH_INDEX"),
+	}
+	switch opts.Content.UpdatedFrom {
+	case content.UpdatedFromNone, content.UpdatedFromGit, content.UpdatedFromMtime:
+	default:
 		flag.Usage()
 		os.Exit(1)
 	}
+	typography, err := content.ParseTypography(os.Getenv("TYPOGRAPHY"))
+	if err != nil {
+		log.Fatalf("TYPOGRAPHY error: %v", err)
+	}
+	opts.Content.Typography = typography
+	if v := opts.Content.CitationStyle; v != "" && !content.ValidCitationStyle(v) {
+		log.Fatalf("CITATION_STYLE error: unknown style %q", v)
+	}
+	if opts.SearchIndex != "" && !search.Valid(opts.SearchIndex) {
+		log.Fatalf("SEARCH_INDEX error: unknown format %q", opts.SearchIndex)
+	}
+	widths, err := images.ParseWidths(os.Getenv("IMAGE_WIDTHS"))
+	if err != nil {
+		log.Fatalf("IMAGE_WIDTHS error: %v", err)
+	}
+	opts.Images.Widths = widths
+	if v := os.Getenv("RELATED_POSTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Fatalf("RELATED_POSTS error: must be a non-negative integer: %q", v)
+		}
+		if n == 0 {
+			n = -1 // None
+		}
+		opts.Content.RelatedPosts = n
+	}
+	if v := os.Getenv("PAGE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("PAGE_SIZE error: must be a positive integer: %q", v)
+		}
+		opts.PageSize = n
+	}
 
-	server, err := web.NewServer(publicURL, contentDir, themeDir)
+	server, err := web.NewServer(publicURL, contentDir, themeDir, opts)
 	if err != nil {
 		log.Fatalf("Server init error: %v", err)
 	}
@@ -53,9 +182,16 @@ Optional environment variables:
 		if outputDir == "" {
 			outputDir = "speakwrite-out"
 		}
-		if err := render.WriteURLs(server, outputDir); err != nil {
+		renderOpts := &render.Options{
+			Precompress: os.Getenv("PRECOMPRESS") == "1",
+		}
+		if err := render.WriteURLs(server, outputDir, renderOpts); err != nil {
 			log.Fatalf("Write error: %v", err)
 		}
+		checkLinks(server)
+
+	case "linkcheck":
+		checkLinks(server)
 
 	case "serve":
 		listenAddr := os.Getenv("LISTEN_ADDR")
@@ -63,8 +199,7 @@ Optional environment variables:
 			listenAddr = "localhost:8080"
 		}
 
-		log.Fatalf("Server listen error: %v",
-			http.ListenAndServe(listenAddr, server))
+		serve(server, listenAddr)
 
 	default:
 		flag.Usage()
@@ -72,3 +207,178 @@ Optional environment variables:
 
 	}
 }
+
+// Returns the duration in an environment variable, or def if it's unset.
+func durationEnv(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("%s error: %v", name, err)
+	}
+	return d
+}
+
+// Serves the site until SIGTERM or SIGINT, then drains open connections.
+// SIGHUP reloads content.
+func serve(server *web.Server, listenAddr string) {
+	logFormat := os.Getenv("LOG_FORMAT")
+	if logFormat == "" {
+		logFormat = accesslog.Common
+	}
+	if !accesslog.Valid(logFormat) {
+		log.Fatalf("LOG_FORMAT error: unknown format %q", logFormat)
+	}
+
+	httpServer := &http.Server{
+		Addr:         listenAddr,
+		Handler:      accesslog.Handler(compress.Handler(server), os.Stderr, logFormat),
+		ReadTimeout:  durationEnv("READ_TIMEOUT", 10*time.Second),
+		WriteTimeout: durationEnv("WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:  durationEnv("IDLE_TIMEOUT", 120*time.Second),
+	}
+	shutdownTimeout := durationEnv("SHUTDOWN_TIMEOUT", 15*time.Second)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for sig := range sigs {
+			if sig == syscall.SIGHUP {
+				if err := server.Reload(); err != nil {
+					log.Printf("Reload error: %v", err)
+				}
+				continue
+			}
+			log.Printf("Received %v; shutting down", sig)
+			ctx, cancel := context.WithTimeout(context.Background(),
+				shutdownTimeout)
+			defer cancel()
+			if err := httpServer.Shutdown(ctx); err != nil {
+				log.Printf("Server shutdown error: %v", err)
+			}
+			return
+		}
+	}()
+
+	log.Printf("Listening on %s", listenAddr)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatalf("Server listen error: %v", err)
+	}
+	<-done
+}
+
+// Lints the content directory, exiting nonzero on any problem.
+func check(contentDir string) {
+	if contentDir == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	problems := content.Check(contentDir)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(problems))
+		os.Exit(1)
+	}
+}
+
+// Checks the site's internal links, exiting nonzero if any are broken.
+func checkLinks(server *web.Server) {
+	urls, err := server.GetURLs()
+	if err != nil {
+		log.Fatalf("linkcheck error: %v", err)
+	}
+	broken, err := linkcheck.Check(server, server.PublicURL, urls)
+	if err != nil {
+		log.Fatalf("linkcheck error: %v", err)
+	}
+	for _, b := range broken {
+		fmt.Println(b)
+	}
+	if len(broken) > 0 {
+		fmt.Fprintf(os.Stderr, "%d broken link(s) found\n", len(broken))
+		os.Exit(1)
+	}
+}
+
+// Creates a new post from "post TITLE [flags]" arguments. Flags may come
+// before or after the title.
+func newPost(contentDir string, args []string) {
+	if contentDir == "" || len(args) == 0 || args[0] != "post" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("new post", flag.ExitOnError)
+	fs.Usage = flag.Usage
+	series := fs.String("series", "", "series name")
+	tags := fs.String("tags", "", "comma-separated tags")
+	date := fs.String("date", "", "post date (default: today)")
+
+	var positional []string
+	for rest := args[1:]; len(rest) > 0; {
+		fs.Parse(rest)
+		rest = fs.Args()
+		if len(rest) > 0 {
+			positional = append(positional, rest[0])
+			rest = rest[1:]
+		}
+	}
+	if len(positional) != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	opts := content.NewPostOptions{
+		Title:  positional[0],
+		Series: *series,
+	}
+	for _, tag := range strings.Split(*tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			opts.Tags = append(opts.Tags, tag)
+		}
+	}
+	if *date != "" {
+		t, err := time.Parse(content.IsoDateFormat, *date)
+		if err != nil {
+			log.Fatalf("new post error: --date: %v", err)
+		}
+		opts.Date = t
+	}
+
+	p, err := content.CreatePost(contentDir, opts)
+	if err != nil {
+		log.Fatalf("new post error: %v", err)
+	}
+	fmt.Println(p)
+}
+
+// Writes the CSS for a code highlighting style into the theme.
+func writeHighlightCSS(themeDir string, args []string) {
+	if themeDir == "" || len(args) != 1 {
+		flag.Usage()
+		fmt.Fprintf(flag.CommandLine.Output(), "\nAvailable styles: %s\n",
+			strings.Join(content.HighlightStyles(), " "))
+		os.Exit(1)
+	}
+
+	p := filepath.Join(themeDir, "static", "highlight.css")
+	f, err := os.Create(p)
+	if err != nil {
+		log.Fatalf("highlight-css error: %v", err)
+	}
+	if err := content.WriteHighlightCSS(f, args[0]); err != nil {
+		f.Close()
+		os.Remove(p)
+		log.Fatalf("highlight-css error: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("highlight-css error: %v", err)
+	}
+	log.Printf("Wrote %s", p)
+}
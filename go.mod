@@ -3,10 +3,14 @@ module github.com/hblanks/speakwrite
 go 1.13
 
 require (
+	github.com/alecthomas/chroma/v2 v2.14.0
+	github.com/andybalholm/brotli v1.1.1
 	github.com/c9s/gomon v1.3.0
 	github.com/gomarkdown/markdown v0.0.0-20200105192015-0948ad373b2c
-	github.com/gorilla/feeds v1.1.1 // indirect
+	github.com/gorilla/feeds v1.1.1
 	github.com/julienschmidt/httprouter v1.3.0
+	golang.org/x/image v0.18.0
+	golang.org/x/net v0.30.0
 )
 
 replace github.com/c9s/gomon => github.com/hblanks/gomon v1.3.1-0.20220415190108-ddca92e920b2
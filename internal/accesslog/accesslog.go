@@ -0,0 +1,122 @@
+package accesslog
+
+//
+// Access logging middleware.
+//
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Log formats.
+const (
+	// Common Log Format, followed by the quoted user agent and the
+	// duration in seconds.
+	Common = "common"
+	JSON   = "json"
+	// Logs nothing.
+	None = "none"
+)
+
+// One logged request.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Remote    string    `json:"remote"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	Duration  float64   `json:"duration"` // In seconds.
+	UserAgent string    `json:"user_agent"`
+}
+
+// Returns the entry in Common Log Format, plus user agent and duration.
+func (e *Entry) String() string {
+	return fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %d %s %.6f",
+		e.Remote, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, e.Path, e.Proto, e.Status, e.Bytes,
+		strconv.Quote(e.UserAgent), e.Duration)
+}
+
+// A ResponseWriter that records the status and the number of bytes
+// written.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Returns whether format is a known log format.
+func Valid(format string) bool {
+	switch format {
+	case Common, JSON, None:
+		return true
+	}
+	return false
+}
+
+// Wraps a handler so that each request is logged to out in the given
+// format, once its response is written.
+func Handler(h http.Handler, out io.Writer, format string) http.Handler {
+	if format == None {
+		return h
+	}
+	logger := log.New(out, "", 0)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		h.ServeHTTP(rw, r)
+
+		e := Entry{
+			Time:      start,
+			Remote:    r.RemoteAddr,
+			Method:    r.Method,
+			Path:      r.URL.RequestURI(),
+			Proto:     r.Proto,
+			Status:    rw.status,
+			Bytes:     rw.bytes,
+			Duration:  time.Since(start).Seconds(),
+			UserAgent: r.UserAgent(),
+		}
+		if host, _, err := net.SplitHostPort(e.Remote); err == nil {
+			e.Remote = host
+		}
+		if e.Status == 0 {
+			e.Status = http.StatusOK
+		}
+
+		if format == JSON {
+			b, err := json.Marshal(&e)
+			if err != nil {
+				return
+			}
+			logger.Print(string(b))
+		} else {
+			logger.Print(e.String())
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func testHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	})
+}
+
+func serve(h http.Handler, path string) {
+	r := httptest.NewRequest("GET", path, nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("User-Agent", `test "agent"`)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestCommon(t *testing.T) {
+	var buf bytes.Buffer
+	serve(Handler(testHandler(), &buf, Common), "/?a=b")
+
+	expected := regexp.MustCompile(`^192\.0\.2\.1 - - \[[^\]]+\] ` +
+		`"GET /\?a=b HTTP/1\.1" 200 5 "test \\"agent\\"" \d+\.\d{6}\n$`)
+	if !expected.Match(buf.Bytes()) {
+		t.Errorf("unexpected log line %q", buf.String())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	serve(Handler(testHandler(), &buf, JSON), "/missing")
+
+	var e Entry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("unexpected error %v on %q", err, buf.String())
+	}
+	if e.Method != "GET" || e.Path != "/missing" || e.Status != 404 ||
+		e.Bytes != 19 || e.Remote != "192.0.2.1" ||
+		e.UserAgent != `test "agent"` {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
+
+func TestNone(t *testing.T) {
+	var buf bytes.Buffer
+	serve(Handler(testHandler(), &buf, None), "/")
+	if buf.Len() != 0 {
+		t.Errorf("unexpected log output %q", buf.String())
+	}
+}
@@ -0,0 +1,218 @@
+package bib
+
+//
+// Bibliographic references, read from BibTeX or CSL-JSON, and formatted
+// for citations and bibliographies.
+//
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// A person's name. Organizations have only a Family name.
+type Name struct {
+	Family string
+	Given  string
+}
+
+// A reference to a work.
+type Entry struct {
+	Key     string
+	Authors []Name
+	// Whether there are more authors than are listed, i.e. "et al.".
+	MoreAuthors bool
+	Editors     []Name
+	Title       string
+	Container   string // Journal, book or proceedings the work is in, if any.
+	Publisher   string
+	Year        string
+	Volume      string
+	Issue       string
+	Pages       string
+	DOI         string
+	URL         string
+}
+
+// Reads references from a file. See Parse.
+func Load(path string) ([]*Entry, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := Parse(path, src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// Parses references from a file's contents: BibTeX if its name ends in
+// .bib, or else CSL-JSON.
+func Parse(name string, src []byte) ([]*Entry, error) {
+	if filepath.Ext(name) == ".bib" {
+		return ParseBibTeX(src)
+	}
+	return ParseCSLJSON(src)
+}
+
+// Returns the names to cite the entry by: its authors, or else its
+// editors.
+func (e *Entry) names() []Name {
+	if len(e.Authors) > 0 {
+		return e.Authors
+	}
+	return e.Editors
+}
+
+// Returns the entry's year, or "n.d." if it has none.
+func (e *Entry) year() string {
+	if e.Year == "" {
+		return "n.d."
+	}
+	return e.Year
+}
+
+// Returns the label to cite the entry by in author-date style, e.g.
+// "Knuth 1984", "Knuth and Plass 1981" or "Lamport et al. 1990".
+func (e *Entry) Label() string {
+	names := e.names()
+	var who string
+	switch len(names) {
+	case 0:
+		who = e.Title
+	case 1:
+		who = names[0].Family
+		if e.MoreAuthors && len(e.Authors) > 0 {
+			who += " et al."
+		}
+	case 2:
+		who = names[0].Family + " and " + names[1].Family
+		if e.MoreAuthors && len(e.Authors) > 0 {
+			who = names[0].Family + " et al."
+		}
+	default:
+		who = names[0].Family + " et al."
+	}
+	return who + " " + e.year()
+}
+
+// Returns a key to sort entries by in author-date style.
+func (e *Entry) SortKey() string {
+	var parts []string
+	for _, n := range e.names() {
+		parts = append(parts, n.Family, n.Given)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, e.Title)
+	}
+	parts = append(parts, e.year(), e.Title)
+	return strings.ToLower(strings.Join(parts, "\x00"))
+}
+
+// Formats a list of names, the first family name first, e.g.
+// "Knuth, Donald E., and Michael F. Plass".
+func formatNames(names []Name) string {
+	var b strings.Builder
+	for i, n := range names {
+		switch {
+		case i == 0:
+		case i == len(names)-1 && len(names) == 2:
+			b.WriteString(" and ")
+		case i == len(names)-1:
+			b.WriteString(", and ")
+		default:
+			b.WriteString(", ")
+		}
+		switch {
+		case n.Given == "":
+			b.WriteString(n.Family)
+		case i == 0:
+			b.WriteString(n.Family + ", " + n.Given)
+		default:
+			b.WriteString(n.Given + " " + n.Family)
+		}
+	}
+	return b.String()
+}
+
+// Returns whether s ends with punctuation that ends a sentence.
+func endsSentence(s string) bool {
+	return strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") ||
+		strings.HasSuffix(s, "!")
+}
+
+// Appends a sentence to b, ending it with a period unless text, the
+// sentence without markup, already ends with punctuation.
+func writeSentence(b *strings.Builder, s, text string) {
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+	b.WriteString(s)
+	if !endsSentence(text) {
+		b.WriteString(".")
+	}
+}
+
+// Returns the entry formatted for a bibliography, as HTML, in roughly
+// Chicago author-date style:
+//
+//	Knuth, Donald E. 1984. “Literate Programming.” <i>The Computer
+//	Journal</i> 27 (2): 97–111. https://doi.org/10.1093/comjnl/27.2.97.
+func (e *Entry) HTML() string {
+	var b strings.Builder
+	esc := html.EscapeString
+
+	switch {
+	case len(e.Authors) > 0 && e.MoreAuthors:
+		s := formatNames(e.Authors) + ", et al."
+		writeSentence(&b, esc(s), s)
+	case len(e.Authors) > 0:
+		s := formatNames(e.Authors)
+		writeSentence(&b, esc(s), s)
+	case len(e.Editors) > 0:
+		s := formatNames(e.Editors) + ", ed"
+		writeSentence(&b, esc(s), s)
+	}
+	writeSentence(&b, esc(e.year()), e.year())
+
+	if e.Container == "" {
+		if e.Title != "" {
+			writeSentence(&b, "<i>"+esc(e.Title)+"</i>", e.Title)
+		}
+	} else {
+		if e.Title != "" {
+			title := e.Title
+			if !endsSentence(title) {
+				title += "."
+			}
+			b.WriteString(" “" + esc(title) + "”")
+		}
+		in := "<i>" + esc(e.Container) + "</i>"
+		if e.Volume != "" {
+			in += " " + esc(e.Volume)
+		}
+		if e.Issue != "" {
+			in += " (" + esc(e.Issue) + ")"
+		}
+		if e.Pages != "" {
+			in += ": " + esc(e.Pages)
+		}
+		writeSentence(&b, in, in)
+	}
+	if e.Publisher != "" {
+		writeSentence(&b, esc(e.Publisher), e.Publisher)
+	}
+
+	link := e.URL
+	if e.DOI != "" {
+		link = "https://doi.org/" + e.DOI
+	}
+	if link != "" {
+		b.WriteString(` <a href="` + esc(link) + `">` + esc(link) + `</a>.`)
+	}
+	return b.String()
+}
@@ -0,0 +1,216 @@
+package bib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testBibTeX = `
+This text is ignored.
+
+@string{tcj = "The Computer Journal"}
+@comment{Also ignored, {even} with braces.}
+
+@article{knuth84,
+  author  = {Knuth, Donald E.},
+  title   = {Literate {P}rogramming},
+  journal = tcj,
+  year    = 1984,
+  volume  = {27},
+  number  = {2},
+  pages   = {97--111},
+  month   = feb,
+  doi     = {10.1093/comjnl/27.2.97},
+}
+
+@book(goedel,
+  author = "Kurt G{\"o}del and Ludwig van Beethoven and {The Music Society}",
+  title = "On Formally \emph{Undecidable} Propositions \& Fugues",
+  publisher = {Dover}
+)
+
+@inproceedings{lamport,
+  author = {Leslie Lamport and others},
+  title = {Time, Clocks},
+  booktitle = {Proc. } # tcj,
+  year = {1978}
+}
+`
+
+func TestParseBibTeX(t *testing.T) {
+	entries, err := ParseBibTeX([]byte(testBibTeX))
+	if err != nil {
+		t.Fatalf("ParseBibTeX returned unexpected error: %v", err)
+	}
+	expected := []*Entry{
+		{
+			Key:       "knuth84",
+			Authors:   []Name{{"Knuth", "Donald E."}},
+			Title:     "Literate Programming",
+			Container: "The Computer Journal",
+			Year:      "1984",
+			Volume:    "27",
+			Issue:     "2",
+			Pages:     "97–111",
+			DOI:       "10.1093/comjnl/27.2.97",
+		},
+		{
+			Key: "goedel",
+			Authors: []Name{
+				{"Gödel", "Kurt"},
+				{"van Beethoven", "Ludwig"},
+				{"The Music Society", ""},
+			},
+			Title:     "On Formally Undecidable Propositions & Fugues",
+			Publisher: "Dover",
+		},
+		{
+			Key:         "lamport",
+			Authors:     []Name{{"Lamport", "Leslie"}},
+			MoreAuthors: true,
+			Title:       "Time, Clocks",
+			Container:   "Proc. The Computer Journal",
+			Year:        "1978",
+		},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		for i := range entries {
+			t.Logf("entry %d: %+v", i, *entries[i])
+		}
+		t.Errorf("ParseBibTeX returned unexpected entries")
+	}
+}
+
+func TestParseBibTeXErrors(t *testing.T) {
+	for src, expected := range map[string]string{
+		"@article{a, title = {x}}\n@book{a, title = {y}}": `line 2: duplicate key "a"`,
+		"@article{a,\n title = {x}":                       "line 2: expected ',' or '}'",
+		"@article{a,\n title = {x":                        "line 2: unbalanced braces",
+		"@article{a, journal = nosuchmacro}":              `line 1: unknown macro "nosuchmacro"`,
+		"@article a":                                      "line 1: expected { after @article",
+	} {
+		_, err := ParseBibTeX([]byte(src))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("ParseBibTeX(%q) returned %v, not %q", src, err, expected)
+		}
+	}
+}
+
+func TestParseCSLJSON(t *testing.T) {
+	src := `[
+		{"id": "knuth84", "type": "article-journal",
+		 "author": [{"family": "Knuth", "given": "Donald E."}],
+		 "title": "Literate Programming",
+		 "container-title": "The Computer Journal",
+		 "issued": {"date-parts": [[1984, 2]]},
+		 "volume": 27, "issue": "2", "page": "97-111"},
+		{"id": 2, "author": [{"literal": "W3C"}], "title": "HTML",
+		 "issued": {"literal": "2021"}}
+	]`
+	entries, err := ParseCSLJSON([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseCSLJSON returned unexpected error: %v", err)
+	}
+	expected := []*Entry{
+		{
+			Key:       "knuth84",
+			Authors:   []Name{{"Knuth", "Donald E."}},
+			Title:     "Literate Programming",
+			Container: "The Computer Journal",
+			Year:      "1984",
+			Volume:    "27",
+			Issue:     "2",
+			Pages:     "97–111",
+		},
+		{
+			Key:     "2",
+			Authors: []Name{{"W3C", ""}},
+			Title:   "HTML",
+			Year:    "2021",
+		},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("ParseCSLJSON returned %+v, %+v", *entries[0], *entries[1])
+	}
+
+	if _, err := ParseCSLJSON([]byte(`[{"id": "a"}, {"id": "a"}]`)); err == nil {
+		t.Errorf("ParseCSLJSON accepted duplicate ids")
+	}
+}
+
+func TestLabel(t *testing.T) {
+	for _, tc := range []struct {
+		entry    Entry
+		expected string
+	}{
+		{Entry{Authors: []Name{{"Knuth", "D."}}, Year: "1984"}, "Knuth 1984"},
+		{Entry{Authors: []Name{{"Knuth", ""}, {"Plass", ""}}, Year: "1981"},
+			"Knuth and Plass 1981"},
+		{Entry{Authors: []Name{{"A", ""}, {"B", ""}, {"C", ""}}}, "A et al. n.d."},
+		{Entry{Authors: []Name{{"Lamport", ""}}, MoreAuthors: true, Year: "1978"},
+			"Lamport et al. 1978"},
+		{Entry{Editors: []Name{{"Editor", ""}}, Year: "2000"}, "Editor 2000"},
+		{Entry{Title: "Anonymous", Year: "1600"}, "Anonymous 1600"},
+	} {
+		if actual := tc.entry.Label(); actual != tc.expected {
+			t.Errorf("Label() = %q, not %q", actual, tc.expected)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	for _, tc := range []struct {
+		entry    Entry
+		expected string
+	}{
+		{
+			Entry{
+				Authors:   []Name{{"Knuth", "Donald E."}},
+				Title:     "Literate Programming",
+				Container: "The Computer Journal",
+				Year:      "1984", Volume: "27", Issue: "2", Pages: "97–111",
+				DOI: "10.1093/comjnl/27.2.97",
+			},
+			`Knuth, Donald E. 1984. “Literate Programming.” ` +
+				`<i>The Computer Journal</i> 27 (2): 97–111. ` +
+				`<a href="https://doi.org/10.1093/comjnl/27.2.97">` +
+				`https://doi.org/10.1093/comjnl/27.2.97</a>.`,
+		},
+		{
+			Entry{
+				Authors:   []Name{{"Kernighan", "Brian"}, {"Ritchie", "Dennis"}},
+				Title:     "The C Programming Language",
+				Publisher: "Prentice Hall",
+				Year:      "1978",
+			},
+			`Kernighan, Brian and Dennis Ritchie. 1978. ` +
+				`<i>The C Programming Language</i>. Prentice Hall.`,
+		},
+		{
+			Entry{
+				Editors: []Name{{"A", "B."}, {"C", "D."}, {"E", "F."}},
+				Title:   "Q&A?",
+			},
+			`A, B., D. C, and F. E, ed. n.d. <i>Q&amp;A?</i>`,
+		},
+	} {
+		if actual := tc.entry.HTML(); actual != tc.expected {
+			t.Errorf("HTML() returned:\n%s\nnot:\n%s", actual, tc.expected)
+		}
+	}
+}
+
+func TestLatexToText(t *testing.T) {
+	for s, expected := range map[string]string{
+		`{\'E}cole \c{c}a \v s`:          "École ça š",
+		`Stra{\ss}e~3`:                   "Straße\u00a03",
+		"\\textbf{Bold}  and\n {braces}": "Bold and braces",
+		"a --- b -- c":                   "a — b – c",
+		`50\% \& \$`:                     "50% & $",
+	} {
+		if actual := latexToText(s); actual != expected {
+			t.Errorf("latexToText(%q) = %q, not %q", s, actual, expected)
+		}
+	}
+}
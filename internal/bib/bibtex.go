@@ -0,0 +1,326 @@
+package bib
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Macros every BibTeX style defines.
+var builtinMacros = map[string]string{
+	"jan": "January", "feb": "February", "mar": "March", "apr": "April",
+	"may": "May", "jun": "June", "jul": "July", "aug": "August",
+	"sep": "September", "oct": "October", "nov": "November", "dec": "December",
+}
+
+type bibParser struct {
+	src    []byte
+	pos    int
+	macros map[string]string
+}
+
+// A SyntaxError is a problem at a line of a BibTeX file.
+type SyntaxError struct {
+	Line int // 1-based
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+func (p *bibParser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{
+		Line: bytes.Count(p.src[:p.pos], []byte("\n")) + 1,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *bibParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// Reads an identifier: an entry type, key, field name or macro name.
+func (p *bibParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n{}()=,#\"", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// Expects a byte, after optional space.
+func (p *bibParser) expect(c byte) error {
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// Reads text up to the closing delimiter that balances an opening one
+// just read, and skips the delimiter.
+func (p *bibParser) balanced(close byte) (string, error) {
+	start, depth := p.pos, 0
+	for ; p.pos < len(p.src); p.pos++ {
+		switch c := p.src[p.pos]; {
+		case c == '\\':
+			p.pos++
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == close && depth == 0:
+			p.pos++
+			return string(p.src[start : p.pos-1]), nil
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unbalanced braces")
+}
+
+// Reads a field value: braced or quoted strings, numbers and macros,
+// joined by #.
+func (p *bibParser) value() (string, error) {
+	var b strings.Builder
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return "", p.errorf("unexpected end of file")
+		}
+		switch c := p.src[p.pos]; {
+		case c == '{':
+			p.pos++
+			s, err := p.balanced('}')
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+		case c == '"':
+			p.pos++
+			s, err := p.balanced('"')
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(s)
+		default:
+			name := p.ident()
+			if name == "" {
+				return "", p.errorf("expected a value")
+			}
+			if v, ok := p.macros[strings.ToLower(name)]; ok {
+				b.WriteString(v)
+			} else if strings.Trim(name, "0123456789") == "" {
+				b.WriteString(name)
+			} else {
+				return "", p.errorf("unknown macro %q", name)
+			}
+		}
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.src[p.pos] != '#' {
+			return b.String(), nil
+		}
+		p.pos++
+	}
+}
+
+// Reads "name = value" pairs separated by commas, up to a closing
+// delimiter.
+func (p *bibParser) fields(close byte) (map[string]string, error) {
+	fields := make(map[string]string)
+	for {
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == close {
+			p.pos++
+			return fields, nil
+		}
+		name := strings.ToLower(p.ident())
+		if name == "" {
+			return nil, p.errorf("expected a field name")
+		}
+		if err := p.expect('='); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = v
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+		} else if p.pos >= len(p.src) || p.src[p.pos] != close {
+			return nil, p.errorf("expected %q or %q", ',', close)
+		}
+	}
+}
+
+// Parses references from BibTeX. Text between entries is ignored, as are
+// @comment and @preamble entries.
+func ParseBibTeX(src []byte) ([]*Entry, error) {
+	p := &bibParser{src: src, macros: make(map[string]string)}
+	for k, v := range builtinMacros {
+		p.macros[k] = v
+	}
+
+	var entries []*Entry
+	seen := make(map[string]bool)
+	for {
+		i := bytes.IndexByte(p.src[p.pos:], '@')
+		if i < 0 {
+			return entries, nil
+		}
+		p.pos += i + 1
+		kind := strings.ToLower(p.ident())
+		p.skipSpace()
+		if p.pos >= len(p.src) || (p.src[p.pos] != '{' && p.src[p.pos] != '(') {
+			return nil, p.errorf("expected { after @%s", kind)
+		}
+		close := byte('}')
+		if p.src[p.pos] == '(' {
+			close = ')'
+		}
+		p.pos++
+
+		switch kind {
+		case "comment", "preamble":
+			if _, err := p.balanced(close); err != nil {
+				return nil, err
+			}
+		case "string":
+			fields, err := p.fields(close)
+			if err != nil {
+				return nil, err
+			}
+			for k, v := range fields {
+				p.macros[k] = v
+			}
+		default:
+			key := p.ident()
+			if key == "" {
+				return nil, p.errorf("@%s has no key", kind)
+			}
+			if seen[key] {
+				return nil, p.errorf("duplicate key %q", key)
+			}
+			seen[key] = true
+			if err := p.expect(','); err != nil {
+				return nil, err
+			}
+			fields, err := p.fields(close)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, newBibTeXEntry(key, fields))
+		}
+	}
+}
+
+func newBibTeXEntry(key string, fields map[string]string) *Entry {
+	e := &Entry{
+		Key:       key,
+		Title:     latexToText(fields["title"]),
+		Publisher: latexToText(fields["publisher"]),
+		Year:      latexToText(fields["year"]),
+		Volume:    latexToText(fields["volume"]),
+		Issue:     latexToText(fields["number"]),
+		Pages:     latexToText(fields["pages"]),
+		DOI:       fields["doi"],
+		URL:       fields["url"],
+	}
+	e.Authors, e.MoreAuthors = parseBibTeXNames(fields["author"])
+	e.Editors, _ = parseBibTeXNames(fields["editor"])
+	for _, name := range []string{"journal", "booktitle"} {
+		if v := fields[name]; v != "" {
+			e.Container = latexToText(v)
+			break
+		}
+	}
+	if e.Publisher == "" {
+		e.Publisher = latexToText(fields["school"] + fields["institution"])
+	}
+	return e
+}
+
+// Splits s on a separator, except within braces.
+func splitOutsideBraces(s string, sep *regexp.Regexp) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+		if depth != 0 {
+			continue
+		}
+		if loc := sep.FindStringIndex(s[i:]); loc != nil && loc[0] == 0 && loc[1] > 0 {
+			parts = append(parts, s[start:i])
+			i += loc[1] - 1
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+var (
+	andRegexp   = regexp.MustCompile(`^\s+and\s+`)
+	commaRegexp = regexp.MustCompile(`^,`)
+	spaceRegexp = regexp.MustCompile(`^\s+`)
+)
+
+// Parses a BibTeX name list, like "Knuth, Donald E. and Michael Plass".
+// Returns whether it ended with "and others", BibTeX's "et al.".
+func parseBibTeXNames(s string) ([]Name, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, false
+	}
+	var names []Name
+	parts := splitOutsideBraces(s, andRegexp)
+	others := strings.TrimSpace(parts[len(parts)-1]) == "others"
+	if others {
+		parts = parts[:len(parts)-1]
+	}
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") &&
+			len(splitOutsideBraces(part, spaceRegexp)) == 1 {
+			names = append(names, Name{Family: latexToText(part)})
+			continue
+		}
+
+		if commas := splitOutsideBraces(part, commaRegexp); len(commas) > 1 {
+			// "von Last, First" or "von Last, Jr, First".
+			names = append(names, Name{
+				Family: latexToText(strings.TrimSpace(commas[0])),
+				Given:  latexToText(strings.TrimSpace(commas[len(commas)-1])),
+			})
+			continue
+		}
+
+		// "First von Last": the family name starts at the first
+		// lowercase word, or else is the last word.
+		words := splitOutsideBraces(part, spaceRegexp)
+		i := len(words) - 1
+		for j, w := range words[:len(words)-1] {
+			if w != "" && unicode.IsLower(rune(w[0])) {
+				i = j
+				break
+			}
+		}
+		names = append(names, Name{
+			Family: latexToText(strings.Join(words[i:], " ")),
+			Given:  latexToText(strings.Join(words[:i], " ")),
+		})
+	}
+	return names, others
+}
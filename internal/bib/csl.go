@@ -0,0 +1,115 @@
+package bib
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// A name in CSL-JSON.
+type cslName struct {
+	Family  string `json:"family"`
+	Given   string `json:"given"`
+	Literal string `json:"literal"`
+}
+
+// A date in CSL-JSON, e.g. {"date-parts": [[2020, 1, 2]]}.
+type cslDate struct {
+	DateParts [][]json.Number `json:"date-parts"`
+	Literal   string          `json:"literal"`
+	Raw       string          `json:"raw"`
+}
+
+// A string or number in CSL-JSON, which allows either for many fields.
+type cslString string
+
+func (s *cslString) UnmarshalJSON(b []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err == nil {
+		*s = cslString(n)
+		return nil
+	}
+	var v string
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*s = cslString(v)
+	return nil
+}
+
+type cslItem struct {
+	ID             cslString `json:"id"`
+	Author         []cslName `json:"author"`
+	Editor         []cslName `json:"editor"`
+	Title          string    `json:"title"`
+	ContainerTitle string    `json:"container-title"`
+	Publisher      string    `json:"publisher"`
+	Issued         *cslDate  `json:"issued"`
+	Volume         cslString `json:"volume"`
+	Issue          cslString `json:"issue"`
+	Page           cslString `json:"page"`
+	DOI            string    `json:"DOI"`
+	URL            string    `json:"URL"`
+}
+
+func cslNames(names []cslName) []Name {
+	var out []Name
+	for _, n := range names {
+		if n.Literal != "" {
+			out = append(out, Name{Family: n.Literal})
+		} else {
+			out = append(out, Name{Family: n.Family, Given: n.Given})
+		}
+	}
+	return out
+}
+
+func (d *cslDate) year() string {
+	switch {
+	case d == nil:
+		return ""
+	case len(d.DateParts) > 0 && len(d.DateParts[0]) > 0:
+		return d.DateParts[0][0].String()
+	case d.Literal != "":
+		return d.Literal
+	case len(d.Raw) >= 4:
+		return d.Raw[:4]
+	}
+	return ""
+}
+
+// Parses references from CSL-JSON, as exported by Zotero and used by
+// pandoc: an array of items.
+func ParseCSLJSON(src []byte) ([]*Entry, error) {
+	var items []cslItem
+	if err := json.Unmarshal(src, &items); err != nil {
+		return nil, err
+	}
+	entries := make([]*Entry, 0, len(items))
+	seen := make(map[string]bool)
+	for i, item := range items {
+		key := string(item.ID)
+		switch {
+		case key == "":
+			return nil, fmt.Errorf("item %d has no id", i)
+		case seen[key]:
+			return nil, fmt.Errorf("duplicate id %q", key)
+		}
+		seen[key] = true
+		entries = append(entries, &Entry{
+			Key:       key,
+			Authors:   cslNames(item.Author),
+			Editors:   cslNames(item.Editor),
+			Title:     item.Title,
+			Container: item.ContainerTitle,
+			Publisher: item.Publisher,
+			Year:      item.Issued.year(),
+			Volume:    string(item.Volume),
+			Issue:     string(item.Issue),
+			Pages:     strings.Replace(string(item.Page), "-", "–", -1),
+			DOI:       item.DOI,
+			URL:       item.URL,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,119 @@
+package bib
+
+//
+// Converts the bits of LaTeX found in BibTeX fields to plain text.
+//
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Accented letters, by accent command and then letter.
+var accents = map[byte][2]string{
+	'\'': {"aeiouyAEIOUYcnszCNSZ", "áéíóúýÁÉÍÓÚÝćńśźĆŃŚŹ"},
+	'`':  {"aeiouAEIOU", "àèìòùÀÈÌÒÙ"},
+	'^':  {"aeiouAEIOU", "âêîôûÂÊÎÔÛ"},
+	'"':  {"aeiouyAEIOU", "äëïöüÿÄËÏÖÜ"},
+	'~':  {"anoANO", "ãñõÃÑÕ"},
+	'c':  {"csCS", "çşÇŞ"},
+	'v':  {"csznrCSZNR", "čšžňřČŠŽŇŘ"},
+	'H':  {"ouOU", "őűŐŰ"},
+}
+
+// Letters written as commands, like \ss.
+var letters = map[string]string{
+	"ss": "ß", "o": "ø", "O": "Ø", "aa": "å", "AA": "Å",
+	"ae": "æ", "AE": "Æ", "oe": "œ", "OE": "Œ", "l": "ł", "L": "Ł", "i": "ı",
+}
+
+// Returns a letter with an accent, or the letter alone if it's not one
+// we know.
+func accented(accent byte, letter string) string {
+	if a, ok := accents[accent]; ok {
+		if i := strings.Index(a[0], letter); i >= 0 && len(letter) == 1 {
+			r := []rune(a[1])
+			return string(r[i])
+		}
+	}
+	return letter
+}
+
+// Converts LaTeX to plain text: removes braces, replaces accents and
+// escapes, and keeps the arguments of other commands like \emph.
+func latexToText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '{' || c == '}':
+			i++
+		case c == '~':
+			b.WriteString("\u00a0") // A non-breaking space.
+			i++
+		case strings.HasPrefix(s[i:], "---"):
+			b.WriteString("—")
+			i += 3
+		case strings.HasPrefix(s[i:], "--"):
+			b.WriteString("–")
+			i += 2
+		case unicode.IsSpace(rune(c)):
+			// Collapse whitespace, including newlines.
+			b.WriteByte(' ')
+			for i < len(s) && unicode.IsSpace(rune(s[i])) {
+				i++
+			}
+		case c == '\\' && i+1 < len(s):
+			i = latexCommand(&b, s, i+1)
+		default:
+			r, n := utf8.DecodeRuneInString(s[i:])
+			b.WriteRune(r)
+			i += n
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Writes the text for the command starting at s[i], just after its
+// backslash, and returns the index after it.
+func latexCommand(b *strings.Builder, s string, i int) int {
+	c := s[i]
+	if _, ok := accents[c]; ok && (!isLetter(c) || i+1 < len(s) && !isLetter(s[i+1])) {
+		// An accent, e.g. \'e, \'{e}, {\c c} or \v{s}.
+		j := i + 1
+		for j < len(s) && (s[j] == '{' || s[j] == ' ') {
+			j++
+		}
+		if j < len(s) && isLetter(s[j]) {
+			b.WriteString(accented(c, s[j:j+1]))
+			j++
+			for j < len(s) && s[j] == '}' {
+				j++
+			}
+			return j
+		}
+		return j
+	}
+	if !isLetter(c) {
+		// An escaped character, like \& or \%.
+		b.WriteByte(c)
+		return i + 1
+	}
+
+	j := i
+	for j < len(s) && isLetter(s[j]) {
+		j++
+	}
+	if l, ok := letters[s[i:j]]; ok {
+		b.WriteString(l)
+	}
+	// Otherwise drop the command, keeping whatever argument follows.
+	for j < len(s) && s[j] == ' ' {
+		j++
+	}
+	return j
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,145 @@
+package compress
+
+//
+// Gzip and Brotli compression, both of live responses and of rendered
+// files.
+//
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Content encodings, in order of preference.
+const (
+	Brotli = "br"
+	Gzip   = "gzip"
+)
+
+// Responses smaller than this aren't worth compressing.
+const minSize = 256
+
+// Returns whether content of a given type is worth compressing.
+func Compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/javascript",
+		"application/json",
+		"application/xml",
+		"application/rss+xml",
+		"application/atom+xml",
+		"application/xhtml+xml",
+		"application/manifest+json",
+		"image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// Returns whether a file is worth compressing, judging by its extension.
+func CompressibleFile(name string) bool {
+	return Compressible(mime.TypeByExtension(filepath.Ext(name)))
+}
+
+// Returns a writer that compresses to w with the given encoding.
+func NewWriter(w io.Writer, encoding string, best bool) io.WriteCloser {
+	switch encoding {
+	case Brotli:
+		level := brotli.DefaultCompression
+		if best {
+			level = brotli.BestCompression
+		}
+		return brotli.NewWriterLevel(w, level)
+	default:
+		level := gzip.DefaultCompression
+		if best {
+			level = gzip.BestCompression
+		}
+		zw, _ := gzip.NewWriterLevel(w, level) // Only errors on bad level.
+		return zw
+	}
+}
+
+// Chooses the preferred encoding acceptable to a client, given its
+// Accept-Encoding header. Returns "" if it accepts neither.
+func Negotiate(acceptEncoding string) string {
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if coding != "" {
+			qs[coding] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range []string{Brotli, Gzip} {
+		q, ok := qs[coding]
+		if !ok {
+			q, ok = qs["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// Writes path.gz and path.br alongside the file at path, for servers
+// that serve precompressed files (e.g. nginx's gzip_static).
+func WriteFiles(path string) error {
+	for _, enc := range []struct{ ext, encoding string }{
+		{".gz", Gzip},
+		{".br", Brotli},
+	} {
+		if err := writeFile(path, path+enc.ext, enc.encoding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(src, dst, encoding string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	zw := NewWriter(out, encoding, true)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
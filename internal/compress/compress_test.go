@@ -0,0 +1,102 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiate(t *testing.T) {
+	for header, expected := range map[string]string{
+		"":                    "",
+		"identity":            "",
+		"gzip":                Gzip,
+		"gzip, deflate, br":   Brotli,
+		"br;q=0.5, gzip":      Gzip,
+		"br;q=0, gzip;q=0":    "",
+		"*":                   Brotli,
+		"GZIP;q=0.8, deflate": Gzip,
+		"br;q=0.1, *;q=0.2":   Gzip,
+	} {
+		if actual := Negotiate(header); actual != expected {
+			t.Errorf("Negotiate(%q) = %q, not %q", header, actual, expected)
+		}
+	}
+}
+
+var testBody = strings.Repeat("<p>Hello, world.</p>\n", 100)
+
+func testHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("ETag", `"abc"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(testBody))
+	})
+}
+
+func TestHandler(t *testing.T) {
+	h := Handler(testHandler())
+
+	for _, test := range []struct {
+		encoding string
+		decode   func([]byte) ([]byte, error)
+	}{
+		{Gzip, func(b []byte) ([]byte, error) {
+			zr, err := gzip.NewReader(bytes.NewReader(b))
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.ReadAll(zr)
+		}},
+		{Brotli, func(b []byte) ([]byte, error) {
+			return ioutil.ReadAll(brotli.NewReader(bytes.NewReader(b)))
+		}},
+	} {
+		t.Run(test.encoding, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept-Encoding", test.encoding)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if enc := w.Header().Get("Content-Encoding"); enc != test.encoding {
+				t.Fatalf("Content-Encoding = %q, not %q", enc, test.encoding)
+			}
+			etag := `"abc-` + test.encoding + `"`
+			if actual := w.Header().Get("ETag"); actual != etag {
+				t.Errorf("ETag = %s, not %s", actual, etag)
+			}
+			body, err := test.decode(w.Body.Bytes())
+			if err != nil {
+				t.Fatalf("decode error: %v", err)
+			}
+			if string(body) != testBody {
+				t.Errorf("decoded body differs from original")
+			}
+
+			r = httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept-Encoding", test.encoding)
+			r.Header.Set("If-None-Match", etag)
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != http.StatusNotModified {
+				t.Errorf("conditional GET returned %d, not 304", w.Code)
+			}
+		})
+	}
+
+	t.Run("identity", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Header().Get("Content-Encoding") != "" || w.Body.String() != testBody {
+			t.Errorf("response compressed without Accept-Encoding")
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package compress
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// A ResponseWriter that decides whether to compress when the response
+// headers are written.
+type responseWriter struct {
+	http.ResponseWriter
+	encoding string
+	// Whether the request's If-None-Match named an ETag for this encoding.
+	conditional bool
+
+	wroteHeader bool
+	zw          io.WriteCloser
+}
+
+// Tags an ETag with an encoding, since a compressed response is not
+// byte-for-byte the same as an uncompressed one.
+func encodedETag(etag, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// Reverses encodedETag for every ETag in an If-None-Match or If-Match
+// header.
+func decodedETags(header string) string {
+	for _, encoding := range []string{Brotli, Gzip} {
+		header = strings.Replace(header, "-"+encoding+`"`, `"`, -1)
+	}
+	return header
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.Header()
+	if code == http.StatusNotModified {
+		// Content-Type is gone by now; echo the ETag the client sent.
+		if etag := h.Get("ETag"); etag != "" && w.conditional {
+			h.Set("ETag", encodedETag(etag, w.encoding))
+		}
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if !Compressible(h.Get("Content-Type")) {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	h.Add("Vary", "Accept-Encoding")
+
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < minSize {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if h.Get("Content-Encoding") != "" {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	if etag := h.Get("ETag"); etag != "" {
+		h.Set("ETag", encodedETag(etag, w.encoding))
+	}
+	if code != http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	h.Del("Content-Length")
+	h.Del("Accept-Ranges")
+	h.Set("Content-Encoding", w.encoding)
+	w.ResponseWriter.WriteHeader(code)
+	w.zw = NewWriter(w.ResponseWriter, w.encoding, false)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.zw != nil {
+		return w.zw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) close() error {
+	if w.zw != nil {
+		return w.zw.Close()
+	}
+	return nil
+}
+
+// Wraps a handler so that text responses are compressed with gzip or
+// Brotli, whichever the client prefers.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := Negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Header.Get("Range") != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &responseWriter{ResponseWriter: w, encoding: encoding}
+		if v := r.Header.Get("If-None-Match"); v != "" {
+			cw.conditional = strings.Contains(v, "-"+encoding+`"`)
+			r.Header.Set("If-None-Match", decodedETags(v))
+		}
+		if v := r.Header.Get("If-Match"); v != "" {
+			r.Header.Set("If-Match", decodedETags(v))
+		}
+		defer cw.close()
+		h.ServeHTTP(cw, r)
+	})
+}
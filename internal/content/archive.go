@@ -0,0 +1,80 @@
+package content
+
+//
+// Groupings of posts by date, for archive pages.
+//
+
+import (
+	"fmt"
+	"time"
+)
+
+// Posts from one month, newest first.
+type Month struct {
+	Year  int
+	Month time.Month
+	Posts []*Post
+}
+
+// Returns the first day of the month, for formatting.
+func (m *Month) Date() time.Time {
+	return time.Date(m.Year, m.Month, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (m *Month) RelativeURL() string {
+	return fmt.Sprintf("/archive/%04d/%02d/", m.Year, int(m.Month))
+}
+
+// Posts from one year, newest first, and grouped by month.
+type Year struct {
+	Year   int
+	Months []*Month
+	Posts  []*Post
+}
+
+func (y *Year) RelativeURL() string {
+	return fmt.Sprintf("/archive/%04d/", y.Year)
+}
+
+// Groups posts, which must be sorted newest first, by year and month.
+func groupByDate(posts []*Post) []*Year {
+	var years []*Year
+	for _, p := range posts {
+		y, m, _ := p.Date.Date()
+		if len(years) == 0 || years[len(years)-1].Year != y {
+			years = append(years, &Year{Year: y})
+		}
+		year := years[len(years)-1]
+		year.Posts = append(year.Posts, p)
+		if len(year.Months) == 0 || year.Months[len(year.Months)-1].Month != m {
+			year.Months = append(year.Months, &Month{Year: y, Month: m})
+		}
+		month := year.Months[len(year.Months)-1]
+		month.Posts = append(month.Posts, p)
+	}
+	return years
+}
+
+// Returns the posts from a year, or nil if there are none.
+func (p *PostIndex) GetYear(year int) *Year {
+	for _, y := range p.Years {
+		if y.Year == year {
+			return y
+		}
+	}
+	return nil
+}
+
+// Returns the posts from a month, or nil if there are none.
+func (p *PostIndex) GetMonth(year int, month time.Month) *Month {
+	y := p.GetYear(year)
+	if y == nil {
+		return nil
+	}
+	for _, m := range y.Months {
+		if m.Month == month {
+			return m
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package content
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGroupByDate(t *testing.T) {
+	var posts []*Post
+	for _, d := range []string{
+		"2021-03-09", "2021-03-01", "2021-01-15", "2019-12-31", "2019-12-01",
+	} {
+		date, _ := time.Parse(IsoDateFormat, d)
+		posts = append(posts, &Post{Name: d, Date: date})
+	}
+
+	years := groupByDate(posts)
+	if len(years) != 2 {
+		t.Fatalf("got %d years, not 2", len(years))
+	}
+	for i, expected := range []struct {
+		year   int
+		months []time.Month
+		counts []int
+	}{
+		{2021, []time.Month{time.March, time.January}, []int{2, 1}},
+		{2019, []time.Month{time.December}, []int{2}},
+	} {
+		y := years[i]
+		if y.Year != expected.year {
+			t.Errorf("years[%d] is %d, not %d", i, y.Year, expected.year)
+		}
+		if len(y.Months) != len(expected.months) {
+			t.Fatalf("%d has %d months, not %d",
+				y.Year, len(y.Months), len(expected.months))
+		}
+		total := 0
+		for j, m := range y.Months {
+			if m.Month != expected.months[j] || len(m.Posts) != expected.counts[j] {
+				t.Errorf("%d month %d is %v with %d posts, not %v with %d",
+					y.Year, j, m.Month, len(m.Posts),
+					expected.months[j], expected.counts[j])
+			}
+			total += len(m.Posts)
+		}
+		if len(y.Posts) != total {
+			t.Errorf("%d has %d posts, not %d", y.Year, len(y.Posts), total)
+		}
+	}
+}
+
+func TestGetYearAndMonth(t *testing.T) {
+	date := time.Date(2021, 3, 9, 0, 0, 0, 0, time.UTC)
+	pi := &PostIndex{Years: groupByDate([]*Post{{Name: "a", Date: date}})}
+
+	if y := pi.GetYear(2021); y == nil || y.RelativeURL() != "/archive/2021/" {
+		t.Errorf("unexpected GetYear(2021) = %+v", y)
+	}
+	if y := pi.GetYear(2020); y != nil {
+		t.Errorf("unexpected GetYear(2020) = %+v", y)
+	}
+	if m := pi.GetMonth(2021, time.March); m == nil || m.RelativeURL() != "/archive/2021/03/" {
+		t.Errorf("unexpected GetMonth(2021, March) = %+v", m)
+	}
+	if m := pi.GetMonth(2021, time.April); m != nil {
+		t.Errorf("unexpected GetMonth(2021, April) = %+v", m)
+	}
+}
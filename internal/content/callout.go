@@ -0,0 +1,159 @@
+package content
+
+//
+// Callouts: note, tip, warning and similar boxes, written as
+// blockquotes starting with a marker, as on GitHub:
+//
+//	> [!WARNING] Optional title
+//	> Text of the warning.
+//
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+)
+
+// Kinds of callout.
+var calloutKinds = map[string]bool{
+	"note":      true,
+	"tip":       true,
+	"important": true,
+	"warning":   true,
+	"caution":   true,
+}
+
+var calloutRegexp = regexp.MustCompile(`^\[!([A-Za-z]+)\][ \t]*(.*)`)
+
+// A callout, replacing the blockquote it was written as. Its first child
+// is its CalloutTitle, if it has one.
+type Callout struct {
+	ast.Container
+
+	Kind string // One of calloutKinds.
+}
+
+// A callout's title, holding the inline content of the marker's line.
+type CalloutTitle struct {
+	ast.Container
+}
+
+// Returns the callout a blockquote is marking up, or nil. On success,
+// removes the marker from the blockquote's text.
+func newCallout(quote *ast.BlockQuote) *Callout {
+	children := quote.GetChildren()
+	if len(children) == 0 {
+		return nil
+	}
+	para, ok := children[0].(*ast.Paragraph)
+	if !ok || len(para.Children) == 0 {
+		return nil
+	}
+	text, ok := para.Children[0].(*ast.Text)
+	if !ok {
+		return nil
+	}
+	m := calloutRegexp.FindSubmatch(text.Literal)
+	if m == nil || !calloutKinds[strings.ToLower(string(m[1]))] {
+		return nil
+	}
+
+	c := &Callout{Kind: strings.ToLower(string(m[1]))}
+	text.Literal = text.Literal[len(m[0])-len(m[2]):]
+	if line := cutFirstLine(para); len(line) > 0 {
+		title := &CalloutTitle{}
+		title.SetParent(c)
+		title.SetChildren(line)
+		c.Children = append(c.Children, title)
+	}
+
+	for _, child := range children {
+		if child == para && len(para.Children) == 0 {
+			continue
+		}
+		child.SetParent(c)
+		c.Children = append(c.Children, child)
+	}
+	return c
+}
+
+// Removes the first line of a paragraph's inline content, and returns
+// it without surrounding space, or nil if it's blank.
+func cutFirstLine(para *ast.Paragraph) []ast.Node {
+	var line []ast.Node
+cut:
+	for len(para.Children) > 0 {
+		child := para.Children[0]
+		switch v := child.(type) {
+		case *ast.Text:
+			if i := bytes.IndexByte(v.Literal, '\n'); i >= 0 {
+				line = append(line, &ast.Text{Leaf: ast.Leaf{Literal: v.Literal[:i]}})
+				v.Literal = v.Literal[i+1:]
+				if len(bytes.TrimSpace(v.Literal)) == 0 {
+					para.Children = para.Children[1:]
+				}
+				break cut
+			}
+		case *ast.Hardbreak, *ast.Softbreak:
+			para.Children = para.Children[1:]
+			break cut
+		}
+		line = append(line, child)
+		para.Children = para.Children[1:]
+	}
+
+	// Trim the line's ends, dropping any text left empty.
+	if len(line) > 0 {
+		if text, ok := line[0].(*ast.Text); ok {
+			text.Literal = bytes.TrimLeft(text.Literal, " \t")
+		}
+		if text, ok := line[len(line)-1].(*ast.Text); ok {
+			text.Literal = bytes.TrimRight(text.Literal, " \t")
+		}
+	}
+	var trimmed []ast.Node
+	for _, node := range line {
+		if text, ok := node.(*ast.Text); !ok || len(text.Literal) > 0 {
+			trimmed = append(trimmed, node)
+		}
+	}
+	return trimmed
+}
+
+// Replaces blockquotes marked up as callouts with Callout nodes.
+func replaceCallouts(doc ast.Node) {
+	var quotes []*ast.BlockQuote
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if quote, ok := node.(*ast.BlockQuote); ok && entering {
+			quotes = append(quotes, quote)
+		}
+		return ast.GoToNext
+	})
+	for _, quote := range quotes {
+		if c := newCallout(quote); c != nil {
+			replaceNode(quote, []ast.Node{c})
+		}
+	}
+}
+
+// Renders a callout as an <aside>.
+func renderCallout(w io.Writer, r *html.Renderer, c *Callout, entering bool) {
+	if !entering {
+		io.WriteString(w, "</aside>\n")
+		return
+	}
+	renderHTMLBlock(w, r, `<aside class="callout callout-`+c.Kind+`">`)
+}
+
+// Renders a callout's title as a paragraph of its own.
+func renderCalloutTitle(w io.Writer, entering bool) {
+	if entering {
+		io.WriteString(w, `<p class="callout-title">`)
+	} else {
+		io.WriteString(w, "</p>\n")
+	}
+}
@@ -0,0 +1,16 @@
+package content
+
+import "testing"
+
+func TestCalloutGolden(t *testing.T) {
+	testGolden(t, "callout", renderOptions{})
+}
+
+func TestCalloutPlainText(t *testing.T) {
+	doc := newParser().Parse([]byte("> [!TIP] A *searchable* title\n> And text.\n"))
+	replaceCallouts(doc)
+	expected := "A searchable title\nAnd text."
+	if actual := plainText(doc); actual != expected {
+		t.Errorf("plainText returned:\n%s\nnot:\n%s", actual, expected)
+	}
+}
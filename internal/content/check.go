@@ -0,0 +1,577 @@
+package content
+
+//
+// Lints a content directory, reporting every problem found rather than
+// stopping at the first one as NewPostIndex does.
+//
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+
+	"github.com/hblanks/speakwrite/internal/bib"
+)
+
+// A Problem is something wrong with a file in the content directory.
+type Problem struct {
+	Path    string
+	Line    int // 1-based; 0 if not known
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Path, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", p.Path, p.Line, p.Message)
+}
+
+// Returns the 1-based line number of offset within src.
+func lineAt(src []byte, offset int) int {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	return bytes.Count(src[:offset], []byte("\n")) + 1
+}
+
+// Returns the line of the first occurrence of needle in src at or after
+// *from, and advances *from past it. Returns 0 if not found.
+func findLine(src, needle []byte, from *int) int {
+	i := bytes.Index(src[*from:], needle)
+	if i < 0 {
+		return 0
+	}
+	i += *from
+	*from = i + len(needle)
+	return lineAt(src, i)
+}
+
+var (
+	atxHeadingRegexp = regexp.MustCompile(`^ {0,3}#{1,6}(?:[ \t]|$)`)
+	underlineRegexp  = regexp.MustCompile(`^ {0,3}(?:=+|-+)[ \t]*$`)
+)
+
+// Returns the offsets of the headings in src, outside code: "#" lines,
+// and paragraphs underlined with "===" or "---".
+func headingOffsets(src []byte) []int {
+	code := codeRanges(src)
+	var offsets []int
+	para := -1 // The offset of the paragraph being read, if any.
+	for offset := 0; offset < len(src); {
+		end := bytes.IndexByte(src[offset:], '\n')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += offset + 1
+		}
+		line := bytes.TrimRight(src[offset:end], "\r\n")
+		switch {
+		case inRanges(code, offset):
+			para = -1
+		case atxHeadingRegexp.Match(line):
+			offsets = append(offsets, offset)
+			para = -1
+		case para >= 0 && underlineRegexp.Match(line):
+			offsets = append(offsets, para)
+			para = -1
+		case len(bytes.TrimSpace(line)) == 0:
+			para = -1
+		case para < 0:
+			para = offset
+		}
+		offset = end
+	}
+	return offsets
+}
+
+// Returns the lines of a document's headings, other than its title.
+// Finds each by its position among the headings in src, unless some
+// can't be told from other text, as in lists or blockquotes. Then finds
+// each by its text, preferring a "#" heading line, or if that has markup
+// in it, as the next heading in src.
+func headingLines(src []byte, doc ast.Node) map[*ast.Heading]int {
+	var headings []*ast.Heading
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if heading, ok := node.(*ast.Heading); ok && entering && !heading.IsTitleblock {
+			headings = append(headings, heading)
+		}
+		return ast.GoToNext
+	})
+	offsets := headingOffsets(src)
+	lines := make(map[*ast.Heading]int)
+	from := 0
+	for i, heading := range headings {
+		if len(offsets) == len(headings) {
+			lines[heading] = lineAt(src, offsets[i])
+			continue
+		}
+		text := []byte(nodeText(heading))
+		line := findLine(src, append([]byte("# "), text...), &from)
+		if line == 0 {
+			line = findLine(src, text, &from)
+		}
+		for _, offset := range offsets {
+			if line == 0 && offset >= from {
+				line = lineAt(src, offset)
+				from = offset + 1
+			}
+		}
+		lines[heading] = line
+	}
+	return lines
+}
+
+type checker struct {
+	problems []Problem
+
+	// The content directory, which paths in messages are relative to.
+	contentDir string
+
+	// Post paths keyed by post name, for finding duplicates.
+	postPaths map[string][]string
+	// Paths of posts in the base series, keyed by post name.
+	basePosts map[string]string
+	// Paths of named series, keyed by series name.
+	seriesPaths map[string]string
+	// Series names of posts, keyed by post name.
+	postSeries map[string][]string
+	// Wiki links found, to check once all posts are known.
+	wikiLinks []checkedWikiLink
+	// Where included snippets are.
+	snippetsDir string
+	// Paths of included files already checked or reported, which may be
+	// included by more than one post.
+	checkedIncludes map[string]bool
+}
+
+type checkedWikiLink struct {
+	path   string
+	line   int
+	target string
+}
+
+func (c *checker) add(path string, line int, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{
+		Path:    path,
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Decodes a metadata.json file into v, reporting syntax errors, type
+// errors and unknown fields.
+func (c *checker) checkJSON(path string, v interface{}) []byte {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		c.add(path, 0, "%v", err)
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(v)
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+	case err == io.ErrUnexpectedEOF:
+		c.add(path, lineAt(src, len(src)), "unexpected end of JSON")
+	case errors.As(err, &syntaxErr):
+		c.add(path, lineAt(src, int(syntaxErr.Offset)), "%v", err)
+	case errors.As(err, &typeErr):
+		c.add(path, lineAt(src, int(typeErr.Offset)), "%v", err)
+	case !c.checkJSONFields(path, src, v):
+		c.add(path, 0, "%v", err)
+	}
+	return src
+}
+
+// Returns the names of the JSON fields of a struct type.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch {
+		case name == "-" || f.PkgPath != "":
+			continue
+		case name == "":
+			name = f.Name
+		}
+		names[strings.ToLower(name)] = true // Matched case-insensitively.
+	}
+	return names
+}
+
+// Reports each key of src, a JSON object, that isn't a field of the
+// struct v points to. Returns whether it found any.
+func (c *checker) checkJSONFields(path string, src []byte, v interface{}) bool {
+	known := jsonFieldNames(reflect.TypeOf(v).Elem())
+	dec := json.NewDecoder(bytes.NewReader(src))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return false
+	}
+	found := false
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return found
+		}
+		line := lineAt(src, int(dec.InputOffset()))
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return found
+		}
+		if name, ok := key.(string); ok && !known[strings.ToLower(name)] {
+			c.add(path, line, "unknown field %q", name)
+			found = true
+		}
+	}
+	return found
+}
+
+func (c *checker) checkSeriesMetadata(path string) {
+	var md SeriesMetadata
+	c.checkJSON(path, &md)
+}
+
+func (c *checker) checkPostMetadata(path string) {
+	var md PostMetadata
+	src := c.checkJSON(path, &md)
+	if src == nil {
+		return
+	}
+
+	from := 0
+	tagsLine := findLine(src, []byte(`"tags"`), &from)
+	for _, tag := range md.Tags {
+		if strings.TrimSpace(tag) == "" {
+			c.add(path, tagsLine, "empty tag")
+		}
+	}
+	if md.Updated != "" {
+		if _, err := parseUpdated(md.Updated); err != nil {
+			from := 0
+			c.add(path, findLine(src, []byte(`"updated"`), &from), "%v", err)
+		}
+	}
+	if md.CitationStyle != "" && !ValidCitationStyle(md.CitationStyle) {
+		from := 0
+		c.add(path, findLine(src, []byte(`"citation_style"`), &from),
+			"unknown citation style %q", md.CitationStyle)
+	}
+}
+
+// Matches raw HTML <img> tags, and alt attributes within them.
+var (
+	imgTagRegexp = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	altRegexp    = regexp.MustCompile(`(?i)\salt\s*=`)
+)
+
+// Returns the text content of a node.
+func nodeText(node ast.Node) string {
+	var buf strings.Builder
+	ast.WalkFunc(node, func(node ast.Node, entering bool) ast.WalkStatus {
+		if leaf := node.AsLeaf(); leaf != nil && entering {
+			buf.Write(leaf.Literal)
+		}
+		return ast.GoToNext
+	})
+	return buf.String()
+}
+
+func (c *checker) checkMarkdown(path string) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		c.add(path, 0, "%v", err)
+		return
+	}
+	doc := newParser().Parse(src)
+
+	if getTitle(doc) == "" {
+		c.add(path, 1, "no title (expected a title block like \"%% Title\")")
+	}
+	c.checkMarkdownText(path, src, doc)
+}
+
+// Checks the wiki links, headings and images in markdown, from a post or
+// a file it includes.
+func (c *checker) checkMarkdownText(path string, src []byte, doc ast.Node) {
+	linkFrom := 0
+	for _, link := range findWikiLinks(doc) {
+		line := findLine(src, []byte("[["+link.target), &linkFrom)
+		c.wikiLinks = append(c.wikiLinks, checkedWikiLink{path, line, link.target})
+	}
+
+	imgFrom := 0
+	lines := headingLines(src, doc)
+	firstLines := make(map[string]int) // By id, of the first heading with it.
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch v := node.(type) {
+		case *ast.Heading:
+			if v.IsTitleblock || v.HeadingID == "" {
+				break
+			}
+			line := lines[v]
+			if first, ok := firstLines[v.HeadingID]; ok {
+				c.add(path, line, "duplicate heading id %q (also on line %d)",
+					v.HeadingID, first)
+			} else {
+				firstLines[v.HeadingID] = line
+			}
+		case *ast.Image:
+			line := findLine(src, v.Destination, &imgFrom)
+			if strings.TrimSpace(nodeText(v)) == "" {
+				c.add(path, line, "image %s has no alt text", v.Destination)
+			}
+			return ast.SkipChildren
+		}
+		return ast.GoToNext
+	})
+
+	for _, loc := range imgTagRegexp.FindAllIndex(src, -1) {
+		tag := src[loc[0]:loc[1]]
+		if !altRegexp.Match(tag) {
+			c.add(path, lineAt(src, loc[0]), "<img> has no alt attribute")
+		}
+	}
+}
+
+// Checks that a post's includes refer to files, without cycles, and the
+// markdown of the files it includes.
+func (c *checker) checkIncludes(path string) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return // Reported by checkMarkdown.
+	}
+	_, paths, err := expandIncludes(src, []string{path}, c.snippetsDir)
+	var includeErr *includeError
+	if errors.As(err, &includeErr) && !c.checkedIncludes[includeErr.path] {
+		// Once, though it may be in a snippet more than one post includes.
+		c.checkedIncludes[includeErr.path] = true
+		c.add(includeErr.path, includeErr.line, "%v", includeErr.err)
+	}
+	for _, path := range paths {
+		if c.checkedIncludes[path] {
+			continue
+		}
+		c.checkedIncludes[path] = true
+		if src, err := ioutil.ReadFile(path); err == nil {
+			c.checkMarkdownText(path, src, newParser().Parse(src))
+		}
+	}
+}
+
+func (c *checker) checkPost(postDir, seriesName string) {
+	basename := filepath.Base(postDir)
+	m := postRegexp.FindStringSubmatch(basename)
+	if m == nil {
+		c.add(postDir, 0, "post directory not in format ${ISO_8601}-${name}")
+	} else if _, err := time.Parse(IsoDateFormat, m[1]); err != nil {
+		c.add(postDir, 0, "invalid date %q in post directory name", m[1])
+	} else {
+		name := m[2]
+		c.postPaths[name] = append(c.postPaths[name], postDir)
+		c.postSeries[name] = append(c.postSeries[name], seriesName)
+		if seriesName == "" {
+			c.basePosts[name] = postDir
+		}
+	}
+
+	c.checkMarkdown(filepath.Join(postDir, "index.md"))
+	c.checkIncludes(filepath.Join(postDir, "index.md"))
+	c.checkCitations(postDir)
+
+	metadataPath := filepath.Join(postDir, "metadata.json")
+	if _, err := os.Stat(metadataPath); err == nil {
+		c.checkPostMetadata(metadataPath)
+	} else if !os.IsNotExist(err) {
+		c.add(metadataPath, 0, "%v", err)
+	}
+}
+
+// Mirrors readPosts, checking each post and series found.
+func (c *checker) checkPosts(postsDir, seriesName string) (postCount int) {
+	infos, err := ioutil.ReadDir(postsDir)
+	if err != nil {
+		c.add(postsDir, 0, "%v", err)
+		return 0
+	}
+
+	metadataPath := filepath.Join(postsDir, "metadata.json")
+	if _, err := os.Stat(metadataPath); err == nil {
+		c.checkSeriesMetadata(metadataPath)
+	} else if !os.IsNotExist(err) {
+		c.add(metadataPath, 0, "%v", err)
+	}
+
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		dir := filepath.Join(postsDir, info.Name())
+		contentPath := filepath.Join(dir, "index.md")
+		switch _, err := os.Stat(contentPath); {
+		case err == nil:
+			c.checkPost(dir, seriesName)
+			postCount++
+
+		case os.IsNotExist(err) && seriesName == "":
+			c.seriesPaths[info.Name()] = dir
+			if c.checkPosts(dir, info.Name()) == 0 {
+				c.add(dir, 0, "series contains no posts")
+			}
+
+		case os.IsNotExist(err):
+			c.add(contentPath, 0, "expected index.md, but not found")
+
+		default:
+			c.add(contentPath, 0, "%v", err)
+		}
+	}
+	return postCount
+}
+
+// Returns path relative to the content directory, for messages.
+func (c *checker) rel(path string) string {
+	rel, err := filepath.Rel(c.contentDir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Reports posts whose names clash with other posts or with series.
+func (c *checker) checkNames() {
+	names := make([]string, 0, len(c.postPaths))
+	for name := range c.postPaths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if paths := c.postPaths[name]; len(paths) > 1 {
+			var others []string
+			for _, path := range paths[1:] {
+				others = append(others, c.rel(path))
+			}
+			c.add(paths[0], 0, "post name %q also used by %s",
+				name, strings.Join(others, ", "))
+		}
+		if base, ok := c.basePosts[name]; ok {
+			if series, ok := c.seriesPaths[name]; ok {
+				c.add(base, 0, "post name %q is also the series at %s",
+					name, c.rel(series))
+			}
+		}
+	}
+}
+
+// Checks a post's references file, and that its citations refer to
+// references in it.
+func (c *checker) checkCitations(postDir string) {
+	mdPath := filepath.Join(postDir, "index.md")
+	md, err := ioutil.ReadFile(mdPath)
+	if err != nil {
+		return // Reported by checkMarkdown.
+	}
+	keys := findCitations(newParser().Parse(md))
+
+	path := findReferences(postDir)
+	if path == "" {
+		if len(keys) > 0 {
+			from := 0
+			c.add(mdPath, findLine(md, []byte("@"+keys[0]), &from),
+				"citation(s) but no %s", strings.Join(referencesFiles, " or "))
+		}
+		return
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		c.add(path, 0, "%v", err)
+		return
+	}
+	entries, err := bib.Parse(path, src)
+	var syntaxErr *bib.SyntaxError
+	var jsonErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		c.add(path, syntaxErr.Line, "%s", syntaxErr.Msg)
+		return
+	case errors.As(err, &jsonErr):
+		c.add(path, lineAt(src, int(jsonErr.Offset)), "%v", err)
+		return
+	case err != nil:
+		c.add(path, 0, "%v", err)
+		return
+	}
+
+	known := make(map[string]bool)
+	for _, e := range entries {
+		known[e.Key] = true
+	}
+	from := 0
+	for _, key := range keys {
+		line := findLine(md, []byte("@"+key), &from)
+		if !known[key] {
+			c.add(mdPath, line, "citation @%s refers to no reference in %s",
+				key, filepath.Base(path))
+		}
+	}
+}
+
+// Reports wiki links that don't refer to any post, or that are bare
+// names of posts in more than one series and none in the base series.
+func (c *checker) checkWikiLinks() {
+	for _, link := range c.wikiLinks {
+		series, name := splitWikiTarget(link.target)
+		var found []string
+		for _, s := range c.postSeries[name] {
+			if series == "" || s == series {
+				found = append(found, s)
+			}
+		}
+		if len(found) == 0 {
+			c.add(link.path, link.line, "wiki link [[%s]] refers to no post",
+				link.target)
+		} else if _, ok := c.basePosts[name]; series == "" && !ok && len(found) > 1 {
+			c.add(link.path, link.line, "%v",
+				ambiguousWikiLinkError(link.target, found))
+		}
+	}
+}
+
+// Checks every post and series in a content directory, returning all
+// problems found.
+func Check(contentDir string) []Problem {
+	c := &checker{
+		contentDir:      contentDir,
+		postPaths:       make(map[string][]string),
+		basePosts:       make(map[string]string),
+		seriesPaths:     make(map[string]string),
+		postSeries:      make(map[string][]string),
+		snippetsDir:     filepath.Join(contentDir, "snippets"),
+		checkedIncludes: make(map[string]bool),
+	}
+	c.checkPosts(filepath.Join(contentDir, "posts"), "")
+	c.checkNames()
+	c.checkWikiLinks()
+	return c.problems
+}
@@ -0,0 +1,121 @@
+package content
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("writeFiles error: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatalf("writeFiles error: %v", err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"posts/2020-01-01-fine/index.md":         "% Fine\n\n![A cat](cat.png)\n",
+		"posts/2020-01-02-untitled/index.md":     "No title here.\n",
+		"posts/2020-01-03-images/index.md":       "% Images\n\n![](a.png)\n\n<img src=\"b.png\">\n",
+		"posts/2020-01-04-meta/index.md":         "% Meta\n\nSee [[fine]] and [[A/fine|again]],\nnot [[nope]] or [[B/fine]].\n",
+		"posts/2020-01-04-meta/metadata.json":    "{\n  \"tags\": [\"a\", \"\"],\n  \"dek\": \"typo\",\n  \"Deck\": \"ok\",\n  \"extra\": {\"a\": 1}\n}\n",
+		"posts/2020-01-05-broken/index.md":       "% Broken\n",
+		"posts/2020-01-05-broken/metadata.json":  "{\n  \"tags\": [\n",
+		"posts/2020-01-06-cites/index.md":        "% Cites\n\nAs [@knuth84] and\n[see @nope, p. 3] say.\n",
+		"posts/2020-01-06-cites/references.bib":  "@book{knuth84,\n  title = {TeX}\n}\n",
+		"posts/2020-01-08-badbib/index.md":       "% Bad bib\n",
+		"posts/2020-01-08-badbib/references.bib": "@book{a, title = {x}}\n@book{b,\n  title = {x\n",
+		"posts/2020-01-07-uncited/index.md":      "% Uncited\n\n[@knuth84]\n",
+		"posts/2020-01-09-headings/index.md":     "% Headings\n\n## Intro\n\n## Setup {#intro}\n\nIntro\n-----\n\n## The *real* thing\n\n## The real thing\n",
+		"posts/2020-01-10-includes/index.md":     "% Includes\n\n{{< include snippet=\"loop\" >}}\n",
+		"posts/2020-01-10-includes-too/index.md": "% Includes too\n\n{{< include snippet=\"loop\" >}}\n",
+		"posts/2020-01-11-missing/index.md":      "% Missing\n\n{{< include file=\"gone.md\" >}}\n",
+		"posts/2020-01-12-bio/index.md":          "% Bio\n\n{{< include snippet=\"bio\" >}}\n",
+		"posts/2020-01-13-bio-too/index.md":      "% Bio too\n\n{{< include snippet=\"bio\" >}}\n",
+		"snippets/bio.md":                        "About me.\n\n![](me.jpg)\n\nSee [[gone]].\n",
+		"snippets/loop.md":                       "Loop\n\n{{< include snippet=\"loop\" >}}\n",
+		"posts/undated/index.md":                 "% Undated\n",
+		"posts/empty/metadata.json":              "{}",
+		"posts/A/2020-02-01-fine/index.md":       "% Fine again\n",
+		"posts/A/2020-02-02-twice/index.md":      "% Twice\n\nSee [[twice]] and [[A/twice]].\n",
+		"posts/B/2020-02-02-twice/index.md":      "% Twice again\n",
+	})
+
+	var actual []string
+	for _, p := range Check(root) {
+		rel, _ := filepath.Rel(root, p.Path)
+		p.Path = filepath.ToSlash(rel)
+		actual = append(actual, p.String())
+	}
+	sort.Strings(actual)
+
+	expected := []string{
+		`posts/2020-01-01-fine: post name "fine" also used by posts/A/2020-02-01-fine`,
+		`posts/A/2020-02-02-twice: post name "twice" also used by posts/B/2020-02-02-twice`,
+		`posts/A/2020-02-02-twice/index.md:3: wiki link [[twice]] is ambiguous: in series A, B`,
+		`posts/2020-01-02-untitled/index.md:1: no title (expected a title block like "% Title")`,
+		`posts/2020-01-03-images/index.md:3: image a.png has no alt text`,
+		`posts/2020-01-03-images/index.md:5: <img> has no alt attribute`,
+		`posts/2020-01-04-meta/index.md:4: wiki link [[nope]] refers to no post`,
+		`posts/2020-01-04-meta/index.md:4: wiki link [[B/fine]] refers to no post`,
+		`posts/2020-01-04-meta/metadata.json:2: empty tag`,
+		`posts/2020-01-04-meta/metadata.json:3: unknown field "dek"`,
+		`posts/2020-01-04-meta/metadata.json:5: unknown field "extra"`,
+		`posts/2020-01-05-broken/metadata.json:3: unexpected end of JSON`,
+		`posts/2020-01-06-cites/index.md:4: citation @nope refers to no reference in references.bib`,
+		`posts/2020-01-08-badbib/references.bib:3: unbalanced braces`,
+		`posts/2020-01-07-uncited/index.md:3: citation(s) but no references.bib or references.json`,
+		`posts/2020-01-09-headings/index.md:5: duplicate heading id "intro" (also on line 3)`,
+		`posts/2020-01-09-headings/index.md:7: duplicate heading id "intro" (also on line 3)`,
+		`posts/2020-01-09-headings/index.md:12: duplicate heading id "the-real-thing" (also on line 10)`,
+		`snippets/loop.md:3: include cycle: loop.md -> loop.md`,
+		`snippets/bio.md:3: image me.jpg has no alt text`,
+		`snippets/bio.md:5: wiki link [[gone]] refers to no post`,
+		`posts/2020-01-11-missing/index.md:3: no file "gone.md" in the post's directory`,
+		`posts/empty: series contains no posts`,
+		`posts/undated: post directory not in format ${ISO_8601}-${name}`,
+	}
+	sort.Strings(expected)
+
+	if len(actual) != len(expected) {
+		t.Fatalf("Check() found %d problems, not %d:\n%v",
+			len(actual), len(expected), actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("problem %d:\n\texpected %s\n\tactual   %s",
+				i, expected[i], actual[i])
+		}
+	}
+}
+
+func TestHeadingLines(t *testing.T) {
+	for src, expected := range map[string][]int{
+		"% T\n\n## One\n\n```\n# Not\n```\n\nTwo\n===\n":     {3, 9},
+		"% T\n\n> ## Quoted\n\n## The *real* thing\n\n# B\n": {3, 5, 7},
+	} {
+		doc := newParser().Parse([]byte(src))
+		lines := headingLines([]byte(src), doc)
+		var actual []int
+		ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+			if heading, ok := node.(*ast.Heading); ok && entering && !heading.IsTitleblock {
+				actual = append(actual, lines[heading])
+			}
+			return ast.GoToNext
+		})
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("headingLines(%q) found lines %v, not %v", src, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package content
+
+//
+// Citations, like [@knuth84] or [see @knuth84, p. 12; @lamport78], of
+// references in a post's references.bib or references.json, with a
+// bibliography of those cited at the end of the post.
+//
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+
+	"github.com/hblanks/speakwrite/internal/bib"
+)
+
+// Citation styles.
+const (
+	CitationsNumeric    = "numeric"     // [1], with references in order cited.
+	CitationsAuthorDate = "author-date" // (Knuth 1984), with references by author.
+)
+
+// Returns whether style is a citation style.
+func ValidCitationStyle(style string) bool {
+	return style == CitationsNumeric || style == CitationsAuthorDate
+}
+
+// Files a post's references may be in, in order of preference.
+var referencesFiles = []string{"references.bib", "references.json"}
+
+var (
+	citationRegexp = regexp.MustCompile(`\[([^\[\]]*@[^\[\]]*)\]`)
+	citeRegexp     = regexp.MustCompile(
+		`^\s*(?:(.*?)\s+)?@(\w[\w:.#$%&+?<>~/-]*?)\s*(?:,\s*(.*?))?\s*$`)
+)
+
+// One reference cited within a citation.
+type Cite struct {
+	Prefix  string // e.g. "see"
+	Key     string
+	Locator string // e.g. "p. 12"
+
+	Entry  *bib.Entry // nil until resolved
+	Number int        // In order of first citation, from 1.
+}
+
+// A citation of one or more references, replacing its text.
+type Citation struct {
+	ast.Leaf
+
+	Cites []*Cite
+	Style string
+}
+
+// The list of references cited in a post.
+type Bibliography struct {
+	ast.Leaf
+
+	Entries []*bib.Entry
+	Style   string
+}
+
+// Parses the text within a citation's brackets, or returns nil if it's
+// not a citation.
+func parseCites(s string) []*Cite {
+	var cites []*Cite
+	for _, part := range strings.Split(s, ";") {
+		m := citeRegexp.FindStringSubmatch(part)
+		if m == nil {
+			return nil
+		}
+		cites = append(cites, &Cite{Prefix: m[1], Key: m[2], Locator: m[3]})
+	}
+	return cites
+}
+
+// Calls fn for each Text node that may contain citations, which are
+// those not within links or images.
+func walkCitationText(doc ast.Node, fn func(*ast.Text)) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node := node.(type) {
+		case *ast.Link, *ast.Image:
+			return ast.SkipChildren
+		case *ast.Text:
+			if entering && citationRegexp.Match(node.Literal) {
+				fn(node)
+			}
+		}
+		return ast.GoToNext
+	})
+}
+
+// Returns the keys of every reference cited in a document, in order.
+func findCitations(doc ast.Node) []string {
+	var keys []string
+	walkCitationText(doc, func(node *ast.Text) {
+		for _, m := range citationRegexp.FindAllSubmatch(node.Literal, -1) {
+			for _, c := range parseCites(string(m[1])) {
+				keys = append(keys, c.Key)
+			}
+		}
+	})
+	return keys
+}
+
+// Returns the path of the references file in a directory, or "" if it
+// has none.
+func findReferences(dir string) string {
+	for _, name := range referencesFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Replaces citations in a document with Citation nodes, and adds a
+// Bibliography of the references cited before any footnotes. Returns an
+// error naming any citations of unknown references, which are left as
+// text.
+func replaceCitations(doc ast.Node, entries []*bib.Entry, style string) error {
+	byKey := make(map[string]*bib.Entry)
+	for _, e := range entries {
+		byKey[e.Key] = e
+	}
+	numbers := make(map[string]int)
+	var cited []*bib.Entry
+	var unknown []string
+
+	walkCitationText(doc, func(node *ast.Text) {
+		var nodes []ast.Node
+		text := node.Literal
+		last := 0
+		for _, m := range citationRegexp.FindAllSubmatchIndex(text, -1) {
+			cites := parseCites(string(text[m[2]:m[3]]))
+			if cites == nil {
+				continue
+			}
+			ok := true
+			for _, c := range cites {
+				if c.Entry = byKey[c.Key]; c.Entry == nil {
+					unknown = append(unknown, "@"+c.Key)
+					ok = false
+				}
+			}
+			if !ok {
+				continue
+			}
+			for _, c := range cites {
+				if numbers[c.Key] == 0 {
+					cited = append(cited, c.Entry)
+					numbers[c.Key] = len(cited)
+				}
+				c.Number = numbers[c.Key]
+			}
+
+			if m[0] > last {
+				nodes = append(nodes, &ast.Text{
+					Leaf: ast.Leaf{Literal: text[last:m[0]]}})
+			}
+			nodes = append(nodes, &Citation{Cites: cites, Style: style})
+			last = m[1]
+		}
+		if len(nodes) == 0 {
+			return
+		}
+		if last < len(text) {
+			nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: text[last:]}})
+		}
+		replaceNode(node, nodes)
+	})
+
+	if len(cited) > 0 {
+		if style == CitationsAuthorDate {
+			sort.SliceStable(cited, func(i, j int) bool {
+				return cited[i].SortKey() < cited[j].SortKey()
+			})
+		}
+		addBeforeFootnotes(doc, &Bibliography{Entries: cited, Style: style})
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown citation(s): %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Adds a block to the end of a document, but before its footnotes.
+func addBeforeFootnotes(doc ast.Node, block ast.Node) {
+	children := doc.GetChildren()
+	i := len(children)
+	for j, child := range children {
+		if _, ok := child.(*ast.Footnotes); ok {
+			i = j
+			break
+		}
+	}
+	block.SetParent(doc)
+	children = append(children[:i], append([]ast.Node{block}, children[i:]...)...)
+	doc.SetChildren(children)
+}
+
+// Returns the id of a reference in the bibliography.
+func referenceID(key string) string {
+	return "ref-" + key
+}
+
+// Renders a citation as links to its references.
+func renderCitation(w io.Writer, c *Citation) {
+	open, close := "[", "]"
+	if c.Style == CitationsAuthorDate {
+		open, close = "(", ")"
+	}
+	io.WriteString(w, `<span class="citation">`+open)
+	for i, cite := range c.Cites {
+		if i > 0 {
+			io.WriteString(w, "; ")
+		}
+		if cite.Prefix != "" {
+			io.WriteString(w, html.EscapeString(cite.Prefix)+" ")
+		}
+		label := strconv.Itoa(cite.Number)
+		if c.Style == CitationsAuthorDate {
+			label = cite.Entry.Label()
+		}
+		io.WriteString(w, `<a href="#`+html.EscapeString(referenceID(cite.Key))+`">`+
+			html.EscapeString(label)+`</a>`)
+		if cite.Locator != "" {
+			io.WriteString(w, ", "+html.EscapeString(cite.Locator))
+		}
+	}
+	io.WriteString(w, close+"</span>")
+}
+
+// Renders a bibliography as a list of references: numbered in the
+// numeric style, or else by author.
+func renderBibliography(w io.Writer, b *Bibliography) {
+	list := "ol"
+	if b.Style == CitationsAuthorDate {
+		list = "ul"
+	}
+	io.WriteString(w, "\n<section class=\"bibliography\">\n<h2>References</h2>\n<"+list+">\n")
+	for _, e := range b.Entries {
+		io.WriteString(w, `<li id="`+html.EscapeString(referenceID(e.Key))+`">`+
+			e.HTML()+"</li>\n")
+	}
+	io.WriteString(w, "</"+list+">\n</section>\n")
+}
@@ -0,0 +1,94 @@
+package content
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+
+	"github.com/hblanks/speakwrite/internal/bib"
+)
+
+func TestParseCites(t *testing.T) {
+	for s, expected := range map[string][]Cite{
+		"@knuth84":              {{Key: "knuth84"}},
+		"see @knuth84, p. 12":   {{Prefix: "see", Key: "knuth84", Locator: "p. 12"}},
+		"@a:b.c; also @d, ch 2": {{Key: "a:b.c"}, {Prefix: "also", Key: "d", Locator: "ch 2"}},
+		"mail me@example.com":   nil,
+		"just text":             nil,
+	} {
+		var actual []Cite
+		for _, c := range parseCites(s) {
+			actual = append(actual, *c)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("parseCites(%q) = %+v, not %+v", s, actual, expected)
+		}
+	}
+}
+
+func TestReplaceCitations(t *testing.T) {
+	entries := []*bib.Entry{
+		{Key: "knuth84", Authors: []bib.Name{{Family: "Knuth"}}, Year: "1984", Title: "LP"},
+		{Key: "abelson", Authors: []bib.Name{{Family: "Abelson"}}, Year: "1985", Title: "SICP"},
+	}
+	md := "As [@knuth84] and [see @abelson, p. 3; @knuth84] say.[^1]\n\n" +
+		"`[@knuth84]` and [a link](/x) [@nope].\n\n" +
+		"[^1]: A note.\n"
+
+	render := func(style string) (string, error) {
+		doc := newParser().Parse([]byte(md))
+		err := replaceCitations(doc, entries, style)
+		return string(markdown.Render(doc, newRenderer(renderOptions{}))), err
+	}
+
+	out, err := render(CitationsNumeric)
+	if err == nil || err.Error() != "unknown citation(s): @nope" {
+		t.Errorf("replaceCitations returned unexpected error: %v", err)
+	}
+	for _, s := range []string{
+		`As <span class="citation">[<a href="#ref-knuth84">1</a>]</span> and ` +
+			`<span class="citation">[see <a href="#ref-abelson">2</a>, p. 3; ` +
+			`<a href="#ref-knuth84">1</a>]</span> say.`,
+		`<code>[@knuth84]</code>`,
+		`[@nope]`,
+		"<section class=\"bibliography\">\n<h2>References</h2>\n<ol>\n" +
+			`<li id="ref-knuth84">Knuth. 1984. <i>LP</i>.</li>` + "\n" +
+			`<li id="ref-abelson">Abelson. 1985. <i>SICP</i>.</li>` + "\n" +
+			"</ol>\n</section>\n\n<div class=\"footnotes\">",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("numeric output lacks %s:\n%s", s, out)
+		}
+	}
+
+	out, _ = render(CitationsAuthorDate)
+	for _, s := range []string{
+		`<span class="citation">(<a href="#ref-knuth84">Knuth 1984</a>)</span>`,
+		`(see <a href="#ref-abelson">Abelson 1985</a>, p. 3; `,
+		"<ul>\n<li id=\"ref-abelson\">",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("author-date output lacks %s:\n%s", s, out)
+		}
+	}
+}
+
+func TestPostWithoutReferences(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"posts/2020-01-01-thanks/index.md": "% Thanks\n\nThanks [cc @alice] for the review.\n",
+	})
+	pi, err := NewPostIndex(root, nil)
+	if err != nil {
+		t.Fatalf("NewPostIndex failed: %v", err)
+	}
+	html, err := pi.Get("", "thanks").HTML()
+	if err != nil {
+		t.Fatalf("HTML() returned error %v", err)
+	}
+	if expected := "<p>Thanks [cc @alice] for the review.</p>"; !strings.Contains(string(html), expected) {
+		t.Errorf("HTML() returned\n%s\nnot containing\n%s", html, expected)
+	}
+}
@@ -0,0 +1,29 @@
+package content
+
+//
+// Heading ids, from the heading's text or an explicit {#id}, and the
+// anchor links to them that themes show on hover.
+//
+
+import (
+	"fmt"
+	"html"
+	"io"
+)
+
+// Returns id, or if ids has it already, id with the first suffix (-1,
+// -2, ...) that it doesn't, and adds the result to ids.
+func uniqueHeadingID(ids map[string]bool, id string) string {
+	unique := id
+	for i := 1; ids[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", id, i)
+	}
+	ids[unique] = true
+	return unique
+}
+
+// Renders a link to a heading, at the end of the heading.
+func renderHeadingAnchor(w io.Writer, id string) {
+	io.WriteString(w, ` <a class="heading-anchor" href="#`+html.EscapeString(id)+
+		`" aria-label="Link to this section">#</a>`)
+}
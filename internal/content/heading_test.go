@@ -0,0 +1,27 @@
+package content
+
+import "testing"
+
+// Returns the anchor rendered at the end of a heading.
+func anchor(id string) string {
+	return ` <a class="heading-anchor" href="#` + id +
+		`" aria-label="Link to this section">#</a>`
+}
+
+func TestHeadingGolden(t *testing.T) {
+	testGolden(t, "heading", renderOptions{})
+}
+
+func TestUniqueHeadingID(t *testing.T) {
+	ids := make(map[string]bool)
+	for _, tc := range []struct{ id, want string }{
+		{"intro", "intro"},
+		{"intro", "intro-1"},
+		{"intro-1", "intro-1-1"},
+		{"intro", "intro-2"},
+	} {
+		if got := uniqueHeadingID(ids, tc.id); got != tc.want {
+			t.Errorf("uniqueHeadingID(%q) = %q, want %q", tc.id, got, tc.want)
+		}
+	}
+}
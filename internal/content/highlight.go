@@ -0,0 +1,80 @@
+package content
+
+//
+// Server-side syntax highlighting for fenced code blocks.
+//
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+)
+
+// Highlighted code is emitted with CSS classes rather than inline styles,
+// so that the theme controls colors. See WriteHighlightCSS.
+var highlightFormatter = chromahtml.New(
+	chromahtml.WithClasses(true),
+	chromahtml.PreventSurroundingPre(true),
+)
+
+// Returns the language named in a code block's info string, e.g. "go"
+// for "```go {linenos=true}".
+func codeBlockLanguage(info []byte) string {
+	if i := bytes.IndexAny(info, "\t {"); i >= 0 {
+		info = info[:i]
+	}
+	return string(info)
+}
+
+// Writes a code block highlighted by language. Returns false (having
+// written nothing) if the block has no language or no lexer exists for
+// it, so the caller can fall back to plain rendering.
+func highlightCodeBlock(w io.Writer, block *ast.CodeBlock) bool {
+	lang := codeBlockLanguage(block.Info)
+	if lang == "" {
+		return false
+	}
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		return false
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, string(block.Literal))
+	if err != nil {
+		return false
+	}
+	var buf bytes.Buffer
+	if err := highlightFormatter.Format(&buf, styles.Fallback, iterator); err != nil {
+		return false
+	}
+
+	io.WriteString(w, `<pre class="chroma"><code class="language-`)
+	html.EscapeHTML(w, []byte(lang))
+	io.WriteString(w, `">`)
+	w.Write(buf.Bytes())
+	io.WriteString(w, "</code></pre>\n")
+	return true
+}
+
+// Writes the CSS for a named chroma style, matching the classes emitted
+// for highlighted code blocks.
+func WriteHighlightCSS(w io.Writer, styleName string) error {
+	style, ok := styles.Registry[styleName]
+	if !ok {
+		return fmt.Errorf("Unknown highlight style %q", styleName)
+	}
+	return highlightFormatter.WriteCSS(w, style)
+}
+
+// Returns the names of all available highlight styles.
+func HighlightStyles() []string {
+	return styles.Names()
+}
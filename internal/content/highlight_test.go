@@ -0,0 +1,49 @@
+package content
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestHighlightCodeBlock(t *testing.T) {
+	t.Run("known language", func(t *testing.T) {
+		var buf bytes.Buffer
+		block := &ast.CodeBlock{Info: []byte("go"), IsFenced: true}
+		block.Literal = []byte("func main() {}\n")
+		if !highlightCodeBlock(&buf, block) {
+			t.Fatalf("highlightCodeBlock() returned false for go")
+		}
+		out := buf.String()
+		if !strings.HasPrefix(out, `<pre class="chroma"><code class="language-go">`) {
+			t.Errorf("unexpected prefix: %s", out)
+		}
+		if !strings.Contains(out, `<span class="kd">func</span>`) {
+			t.Errorf("keyword not highlighted: %s", out)
+		}
+	})
+
+	t.Run("unknown language", func(t *testing.T) {
+		var buf bytes.Buffer
+		block := &ast.CodeBlock{Info: []byte("no-such-language"), IsFenced: true}
+		block.Literal = []byte("x\n")
+		if highlightCodeBlock(&buf, block) || buf.Len() != 0 {
+			t.Errorf("highlightCodeBlock() handled an unknown language")
+		}
+	})
+}
+
+func TestWriteHighlightCSS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHighlightCSS(&buf, "monokai"); err != nil {
+		t.Fatalf("WriteHighlightCSS() returned unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), ".chroma") {
+		t.Errorf("WriteHighlightCSS() wrote no .chroma rules")
+	}
+	if err := WriteHighlightCSS(&buf, "no-such-style"); err == nil {
+		t.Errorf("WriteHighlightCSS() accepted an unknown style")
+	}
+}
@@ -0,0 +1,160 @@
+package content
+
+//
+// Includes: {{< include snippet="name" >}} in a post, replaced before
+// parsing (and before shortcodes) by CONTENT_DIR/snippets/name.md, or
+// {{< include file="path.md" >}}, by a file within the post's directory.
+// Included files may include others.
+//
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// How deeply includes may nest.
+const maxIncludeDepth = 8
+
+// An error in an include, at a line of the file that has it.
+type includeError struct {
+	path string
+	line int
+	err  error
+}
+
+func (e *includeError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.path, e.line, e.err)
+}
+
+func (e *includeError) Unwrap() error {
+	return e.err
+}
+
+// Returns the path of rel within dir, or an error if it's outside it.
+// Errors name dir as what, e.g. "the post's directory", so that they read
+// the same wherever the content directory is.
+func pathWithin(dir, rel, what string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	if filepath.IsAbs(rel) || !strings.HasPrefix(path, filepath.Clean(dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("include %q is outside %s", rel, what)
+	}
+	return path, nil
+}
+
+// Returns the path of the file an include's parameters refer to.
+func includePath(params map[string]string, postDir, snippetsDir string) (string, error) {
+	snippet, file := params["snippet"], params["file"]
+	switch {
+	case (snippet == "") == (file == ""):
+		return "", errors.New(`include needs one of snippet="name" or file="path"`)
+	case snippet != "":
+		return pathWithin(snippetsDir, snippet+".md", "the snippets directory")
+	default:
+		return pathWithin(postDir, file, "the post's directory")
+	}
+}
+
+// Expands the includes in src, a post's markdown. stack holds the paths
+// of the files being expanded, the post's first. Returns the paths of
+// the files included, which any caches of the post depend on.
+func expandIncludes(src []byte, stack []string, snippetsDir string) ([]byte, []string, error) {
+	path := stack[len(stack)-1]
+	postDir := filepath.Dir(stack[0])
+
+	var out bytes.Buffer
+	var paths []string
+	last := 0
+	for _, m := range findShortcodes(src) {
+		if string(src[m[4]:m[5]]) != "include" {
+			continue
+		}
+		fail := func(err error) ([]byte, []string, error) {
+			return nil, nil, &includeError{path, lineAt(src, m[0]), err}
+		}
+		if m[3] > m[2] {
+			return fail(errors.New("include has no closing tag"))
+		}
+		params := shortcodeParams(src[m[6]:m[7]])
+		included, err := includePath(params, postDir, snippetsDir)
+		if err != nil {
+			return fail(err)
+		}
+		for i, p := range stack {
+			if p == included {
+				var names []string
+				for _, p := range append(stack[i:], included) {
+					names = append(names, filepath.Base(p))
+				}
+				return fail(fmt.Errorf("include cycle: %s", strings.Join(names, " -> ")))
+			}
+		}
+		if len(stack) > maxIncludeDepth {
+			return fail(fmt.Errorf("includes nested more than %d deep", maxIncludeDepth))
+		}
+
+		text, err := ioutil.ReadFile(included)
+		if os.IsNotExist(err) {
+			if params["snippet"] != "" {
+				return fail(fmt.Errorf("no snippet %q in the snippets directory", params["snippet"]))
+			}
+			return fail(fmt.Errorf("no file %q in the post's directory", params["file"]))
+		} else if err != nil {
+			return fail(err)
+		}
+		paths = append(paths, included)
+		text, nested, err := expandIncludes(text, append(stack[:len(stack):len(stack)], included), snippetsDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		paths = append(paths, nested...)
+
+		out.Write(src[last:m[0]])
+		out.Write(bytes.TrimRight(text, "\n"))
+		last = m[1]
+	}
+	if paths == nil {
+		return src, nil, nil
+	}
+	out.Write(src[last:])
+	return out.Bytes(), paths, nil
+}
+
+// Returns the file, and the line within it, that line n of src came from
+// once expandIncludes expanded it.
+func includedLine(src []byte, stack []string, snippetsDir string, n int) (string, int) {
+	shift := 0 // Lines that includes before line n added.
+	for _, m := range findShortcodes(src) {
+		if string(src[m[4]:m[5]]) != "include" {
+			continue
+		}
+		start := lineAt(src, m[0]) + shift // Where the included text starts.
+		if n < start {
+			break
+		}
+		included, err := includePath(shortcodeParams(src[m[6]:m[7]]),
+			filepath.Dir(stack[0]), snippetsDir)
+		if err != nil {
+			break
+		}
+		text, err := ioutil.ReadFile(included)
+		if err != nil {
+			break
+		}
+		nested := append(stack[:len(stack):len(stack)], included)
+		expanded, _, err := expandIncludes(text, nested, snippetsDir)
+		if err != nil {
+			break
+		}
+		lines := bytes.Count(bytes.TrimRight(expanded, "\n"), []byte("\n")) + 1
+		if n < start+lines {
+			return includedLine(text, nested, snippetsDir, n-start+1)
+		}
+		shift += lines - 1 - (lineAt(src, m[1]) - lineAt(src, m[0]))
+	}
+	return stack[len(stack)-1], n - shift
+}
@@ -0,0 +1,121 @@
+package content
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncludes(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"snippets/intro.md":            "This series is about *Go*.\n\n{{< include snippet=\"legal/disclaimer\" >}}\n",
+		"snippets/legal/disclaimer.md": "Opinions are my own. See [[about]].\n",
+		"posts/2020-01-01-included/index.md": "% Included\n\n" +
+			"{{< include snippet=\"intro\" >}}\n\n" +
+			"Text, then {{< include file=\"notes/aside.md\" >}}.\n\n" +
+			"Not in `{{< include snippet=\"intro\" >}}` spans\n",
+		"posts/2020-01-01-included/notes/aside.md": "an aside\n",
+		"posts/2020-01-01-about/index.md":          "% About\n\nMe.\n",
+	})
+	pi, err := NewPostIndex(root, nil)
+	if err != nil {
+		t.Fatalf("NewPostIndex failed: %v", err)
+	}
+	post := pi.Get("", "included")
+	html, err := post.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"<p>This series is about <em>Go</em>.</p>\n\n" +
+			`<p>Opinions are my own. See <a href="/posts/about/">About</a>.</p>`,
+		"<p>Text, then an aside.</p>",
+		`<code>{{&lt; include snippet=&quot;intro&quot; &gt;}}</code>`,
+	} {
+		if !strings.Contains(string(html), expected) {
+			t.Errorf("HTML() returned\n%s\nnot containing\n%s", html, expected)
+		}
+	}
+
+	// Links in included text are backlinks.
+	if backlinks := pi.GetBacklinks(pi.Get("", "about")); len(backlinks) != 1 || backlinks[0] != post {
+		t.Errorf("GetBacklinks() returned %v", backlinks)
+	}
+
+	// Changing a snippet changes the post's ModTime.
+	future := time.Now().Add(time.Hour).Truncate(time.Second)
+	err = os.Chtimes(filepath.Join(root, "snippets/legal/disclaimer.md"), future, future)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modTime, err := post.ModTime(); err != nil || !modTime.Equal(future) {
+		t.Errorf("ModTime() = %v, %v, want %v", modTime, err, future)
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"snippets/a.md":    "{{< include snippet=\"b\" >}}\n",
+		"snippets/b.md":    "B\n\n{{< include snippet=\"a\" >}}\n",
+		"snippets/deep.md": "{{< include snippet=\"deep\" >}}\n",
+		"post/index.md":    "% Post\n",
+	})
+	for i := 0; i <= maxIncludeDepth; i++ {
+		writeFiles(t, root, map[string]string{
+			fmt.Sprintf("snippets/d%d.md", i): fmt.Sprintf("{{< include snippet=\"d%d\" >}}\n", i+1),
+		})
+	}
+	postPath := filepath.Join(root, "post", "index.md")
+	snippetsDir := filepath.Join(root, "snippets")
+	for _, tc := range []struct{ src, expected string }{
+		{`{{< include snippet="a" >}}`, "b.md:3: include cycle: a.md -> b.md -> a.md"},
+		{`{{< include snippet="deep" >}}`, "deep.md:1: include cycle: deep.md -> deep.md"},
+		{`{{< include snippet="d0" >}}`, "includes nested more than 8 deep"},
+		{"\n{{< include snippet=\"nope\" >}}", "index.md:2: no snippet \"nope\" in the snippets directory"},
+		{`{{< include file="../../x.md" >}}`, `include "../../x.md" is outside the post's directory`},
+		{`{{< include snippet="../posts/x" >}}`, `include "../posts/x.md" is outside the snippets directory`},
+		{`{{< include >}}`, `include needs one of snippet="name" or file="path"`},
+		{`{{< include file="index.md" >}}`, "include cycle: index.md -> index.md"},
+	} {
+		_, _, err := expandIncludes([]byte(tc.src), []string{postPath}, snippetsDir)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("expandIncludes(%q) returned error %v, want %q", tc.src, err, tc.expected)
+		}
+	}
+}
+
+func TestIncludedLine(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"snippets/a.md": "A1\nA2\n{{< include snippet=\"b\" >}}\nA4\n",
+		"snippets/b.md": "B1\nB2\n",
+		"post/index.md": "% Post\n\n{{< include snippet=\"a\" >}}\n\nText\n",
+	})
+	postPath := filepath.Join(root, "post", "index.md")
+	snippetsDir := filepath.Join(root, "snippets")
+	src, err := os.ReadFile(postPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expanded, _, err := expandIncludes(src, []string{postPath}, snippetsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each line of the expanded text comes from a line with the same text.
+	for n, text := range strings.Split(string(expanded), "\n") {
+		path, line := includedLine(src, []string{postPath}, snippetsDir, n+1)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(string(data), "\n")
+		if line < 1 || line > len(lines) || lines[line-1] != text {
+			t.Errorf("includedLine(%d) = %s:%d, not the line of %q", n+1, path, line, text)
+		}
+	}
+}
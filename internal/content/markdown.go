@@ -9,6 +9,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	_ "github.com/gomarkdown/markdown"
 	"github.com/gomarkdown/markdown/ast"
@@ -19,6 +20,9 @@ import (
 // Tweaks Markdown rendering so that:
 //	- the pandoc-style title block is not rendered
 //	- HTML comments are not excluded from output
+//	- fenced code blocks with a known language are syntax highlighted
+//	- callouts are rendered as <aside>s
+//	- citations are rendered as links to the bibliography
 func nodeHook(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
 	switch v := node.(type) {
 	case *ast.Heading:
@@ -33,25 +37,116 @@ func nodeHook(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool)
 		if bytes.HasPrefix(v.Literal, []byte("<!--")) {
 			return ast.SkipChildren, true
 		}
+	case *ast.CodeBlock:
+		if highlightCodeBlock(w, v) {
+			return ast.GoToNext, true
+		}
+	case *Citation:
+		renderCitation(w, v)
+		return ast.GoToNext, true
+	case *Bibliography:
+		renderBibliography(w, v)
+		return ast.GoToNext, true
 	}
 	return ast.GoToNext, false
 }
 
-var mdrenderer = html.NewRenderer(html.RendererOptions{
-	Title:                      "A custom title",
-	Flags:                      html.CommonFlags | html.FootnoteReturnLinks,
-	RenderNodeHook:             html.RenderNodeFunc(nodeHook),
-	FootnoteReturnLinkContents: "↰",
-})
+// Options for rendering a document.
+type renderOptions struct {
+	// Whether to repeat footnotes as sidenotes beside their references.
+	sidenotes bool
+	// Whether to convert math to MathML.
+	mathml bool
+	// Transforms the document's text has had, if any, in place of the
+	// renderer's own smartypants.
+	typography Typography
+}
+
+// The renderer's smart punctuation flags.
+const smartypantsFlags = html.Smartypants | html.SmartypantsFractions |
+	html.SmartypantsDashes | html.SmartypantsLatexDashes
+
+// Returns a new renderer. Renderers track heading IDs to keep them unique
+// within a document, so each document needs its own. Headings end with an
+// anchor link to themselves.
+func newRenderer(opts renderOptions) *html.Renderer {
+	var r *html.Renderer
+	mathBlocks := make(map[ast.Node]bool) // Those rendered as MathML.
+	headingIDs := make(map[string]bool)
+	hook := func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+		switch v := node.(type) {
+		case *ast.Heading:
+			// Made unique here rather than by the renderer, so that
+			// the anchor links to the id the heading ends up with.
+			if !v.IsTitleblock && v.HeadingID != "" {
+				if entering {
+					v.HeadingID = uniqueHeadingID(headingIDs, v.HeadingID)
+				} else {
+					renderHeadingAnchor(w, v.HeadingID)
+				}
+			}
+		case *Callout:
+			renderCallout(w, r, v, entering)
+			return ast.GoToNext, true
+		case *CalloutTitle:
+			renderCalloutTitle(w, entering)
+			return ast.GoToNext, true
+		case *Shortcode:
+			if entering {
+				renderShortcode(w, r, v)
+			}
+			return ast.SkipChildren, true
+		case *ast.Link:
+			if opts.sidenotes && v.NoteID != 0 && !entering {
+				renderSidenote(w, r, v)
+				return ast.GoToNext, true
+			}
+		case *ast.Math:
+			if opts.mathml && renderMathML(w, v.Literal, false) {
+				return ast.GoToNext, true
+			}
+		case *ast.MathBlock:
+			if opts.mathml && entering {
+				mathBlocks[v] = renderMathML(w, v.Literal, true)
+			}
+			if mathBlocks[v] {
+				return ast.GoToNext, true
+			}
+		}
+		return nodeHook(w, node, entering)
+	}
+	flags := html.CommonFlags | html.FootnoteReturnLinks
+	if opts.typography != TypographyNone {
+		flags &^= smartypantsFlags
+	}
+	r = html.NewRenderer(html.RendererOptions{
+		Title:                      "A custom title",
+		Flags:                      flags,
+		RenderNodeHook:             html.RenderNodeFunc(hook),
+		FootnoteReturnLinkContents: "↰",
+	})
+	return r
+}
+
+// Renders raw HTML on lines of its own, as the renderer does HTML blocks.
+func renderHTMLBlock(w io.Writer, r *html.Renderer, s string) {
+	r.RenderNode(w, &ast.HTMLBlock{Leaf: ast.Leaf{Literal: []byte(s)}}, true)
+}
 
 const IsoDateFormat = "2006-01-02"
 
-// Parses a markdown file at a given path.
-func parseMarkdown(path string) (ast.Node, error) {
-	mdparser := parser.NewWithExtensions(
+// Returns a new parser. Parsers hold per-document state, so each
+// document needs its own.
+func newParser() *parser.Parser {
+	return parser.NewWithExtensions(
 		parser.CommonExtensions | parser.Footnotes |
 			parser.MathJax | parser.AutoHeadingIDs | parser.Titleblock,
 	)
+}
+
+// Parses a markdown file at a given path.
+func parseMarkdown(path string) (ast.Node, error) {
+	mdparser := newParser()
 
 	// log.Printf("content.parse: %s", path)
 	f, err := os.Open(path)
@@ -69,22 +164,65 @@ func parseMarkdown(path string) (ast.Node, error) {
 // Walks the AST and returns the title. That's literally all
 // we're parsing the markdown for.
 func getTitle(doc ast.Node) string {
-	var title string
+	var title strings.Builder
 	var inTitle bool
 	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
 		if node, ok := node.(*ast.Heading); ok && node.IsTitleblock {
-			if entering {
-				inTitle = true
-				return ast.GoToNext
+			if !entering {
+				return ast.Terminate
 			}
+			inTitle = true
+			return ast.GoToNext
 		}
 		if inTitle {
-			if node, ok := node.(*ast.Text); ok {
-				title = string(node.Literal)
-				return ast.Terminate
+			switch node := node.(type) {
+			case *ast.Text:
+				title.Write(node.Literal)
+			case *ast.Code:
+				title.Write(node.Literal)
+			case *ast.Math:
+				// Kept as LaTeX, for MathJax or mathml.ToText.
+				title.WriteString("$" + string(node.Literal) + "$")
+			}
+		}
+		return ast.GoToNext
+	})
+	return strings.TrimSpace(title.String())
+}
+
+// Walks the AST and returns its text without markup, one paragraph (or
+// heading, or table cell) per line. Leaves out the title, code blocks,
+// math and raw HTML.
+func plainText(doc ast.Node) string {
+	var b strings.Builder
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node := node.(type) {
+		case *ast.Heading:
+			if node.IsTitleblock {
+				return ast.SkipChildren
 			}
+			if !entering {
+				b.WriteByte('\n')
+			}
+		case *ast.Paragraph, *ast.TableCell, *CalloutTitle:
+			if !entering {
+				b.WriteByte('\n')
+			}
+		case *ast.Text:
+			b.Write(bytes.ReplaceAll(node.Literal, []byte("\n"), []byte(" ")))
+		case *ast.Code:
+			b.Write(bytes.ReplaceAll(node.Literal, []byte("\n"), []byte(" ")))
+		case *ast.Softbreak, *ast.Hardbreak, *ast.NonBlockingSpace:
+			b.WriteByte(' ')
 		}
 		return ast.GoToNext
 	})
-	return title
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			lines = append(lines, strings.Join(fields, " "))
+		}
+	}
+	return strings.Join(lines, "\n")
 }
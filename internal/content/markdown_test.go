@@ -0,0 +1,68 @@
+package content
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// Renders each testdata/<dir>/*.md and compares it with the .html file
+// beside it. Run with -update to rewrite the .html files.
+func testGolden(t *testing.T, dir string, opts renderOptions) {
+	paths, err := filepath.Glob(filepath.Join("testdata", dir, "*.md"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no golden files in %s: %v", dir, err)
+	}
+	for _, path := range paths {
+		path := path
+		name := strings.TrimSuffix(filepath.Base(path), ".md")
+		t.Run(name, func(t *testing.T) {
+			src, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc := newParser().Parse(src)
+			replaceCallouts(doc)
+			got := markdown.Render(doc, newRenderer(opts))
+
+			golden := strings.TrimSuffix(path, ".md") + ".html"
+			if *update {
+				if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	md := "% The Title\n\n" +
+		"# A *heading*\n\n" +
+		"Some **bold**\ntext with `code` and a [link](/x).[^1]\n\n" +
+		"```go\nfunc main() {}\n```\n\n" +
+		"<div>raw html</div>\n\n" +
+		"- one\n- two\n\n" +
+		"[^1]: A footnote.\n"
+	expected := "A heading\n" +
+		"Some bold text with code and a link.\n" +
+		"one\n" +
+		"two\n" +
+		"A footnote."
+	doc := newParser().Parse([]byte(md))
+	if actual := plainText(doc); actual != expected {
+		t.Errorf("plainText returned:\n%s\nnot:\n%s", actual, expected)
+	}
+}
@@ -0,0 +1,64 @@
+package content
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/gomarkdown/markdown/ast"
+
+	"github.com/hblanks/speakwrite/internal/mathml"
+)
+
+// Renders LaTeX math as MathML. Returns false, having written nothing,
+// if the math uses anything mathml doesn't support, so that it can be
+// rendered as LaTeX for MathJax or the like instead.
+func renderMathML(w io.Writer, tex []byte, display bool) bool {
+	out, err := mathml.ToMathML(string(tex), display)
+	if err != nil {
+		return false
+	}
+	if display {
+		out = "\n" + out + "\n"
+	}
+	io.WriteString(w, out)
+	return true
+}
+
+// Returns the errors converting a post's math to MathML, one per piece of
+// math that will be rendered as LaTeX instead.
+func mathErrors(post *Post) ([]error, error) {
+	doc, err := post.parseExpanded()
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		var tex []byte
+		var display bool
+		switch node := node.(type) {
+		case *ast.Math:
+			tex = node.Literal
+		case *ast.MathBlock:
+			tex, display = node.Literal, true
+		}
+		if tex != nil && entering {
+			if _, err := mathml.ToMathML(string(tex), display); err != nil {
+				errs = append(errs, fmt.Errorf("%q: %w", tex, err))
+			}
+		}
+		return ast.GoToNext
+	})
+	return errs, nil
+}
+
+// Logs a warning, once, if some of a post's math will be rendered as
+// LaTeX rather than MathML.
+func warnMath(post *Post) {
+	errs, err := mathErrors(post)
+	if err != nil || len(errs) == 0 {
+		return // Errors parsing the post surface elsewhere.
+	}
+	log.Printf("%s: warning: rendering %d piece(s) of math as LaTeX, first: %v",
+		post.ContentPath, len(errs), errs[0])
+}
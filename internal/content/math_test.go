@@ -0,0 +1,28 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMathGolden(t *testing.T) {
+	testGolden(t, "math", renderOptions{mathml: true})
+}
+
+func TestMathErrors(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"posts/2020-01-01-math/index.md": "% Math\n\n$x^2$ and $\\begin{pmatrix} a \\end{pmatrix}$\n",
+	})
+	pi, err := NewPostIndex(root, &Options{MathML: true})
+	if err != nil {
+		t.Fatalf("NewPostIndex failed: %v", err)
+	}
+	errs, err := mathErrors(pi.Get("", "math"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), `"\\begin{pmatrix} a \\end{pmatrix}": `) {
+		t.Errorf("mathErrors returned %v", errs)
+	}
+}
@@ -0,0 +1,30 @@
+package content
+
+// Sources from which a post's Updated time may be inferred when its
+// metadata doesn't say.
+const (
+	UpdatedFromNone  = ""      // Only use metadata.json.
+	UpdatedFromGit   = "git"   // Last commit touching the post directory.
+	UpdatedFromMtime = "mtime" // Newest file modification time.
+)
+
+// Options for loading and rendering content.
+type Options struct {
+	// One of the UpdatedFrom* constants.
+	UpdatedFrom string
+	// How many related posts to find for each post (default:
+	// DefaultRelatedPosts). Negative for none.
+	RelatedPosts int
+	// Where shortcode templates are, if anywhere.
+	ShortcodesDir string
+	// Whether to render footnotes as sidenotes too, unless a post's
+	// metadata says otherwise.
+	Sidenotes bool
+	// How to cite references: CitationsNumeric (the default) or
+	// CitationsAuthorDate, unless a post's metadata says otherwise.
+	CitationStyle string
+	// Whether to render math as MathML rather than leave it for MathJax.
+	MathML bool
+	// Typographic transforms to apply to posts and their titles.
+	Typography Typography
+}
@@ -15,6 +15,9 @@ import (
 	"time"
 
 	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
+
+	"github.com/hblanks/speakwrite/internal/bib"
 )
 
 //
@@ -23,6 +26,18 @@ import (
 type PostMetadata struct {
 	Tags []string `json:"tags"`
 	Deck string   `json:"deck"` // the "deck" or "drop line" of the post
+
+	// When the post was last revised, as an ISO 8601 date or an RFC 3339
+	// time. Optional.
+	Updated string `json:"updated,omitempty"`
+
+	// Whether to render footnotes as sidenotes. Optional; overrides
+	// Options.Sidenotes.
+	Sidenotes *bool `json:"sidenotes,omitempty"`
+
+	// How to cite references: "numeric" or "author-date". Optional;
+	// overrides Options.CitationStyle.
+	CitationStyle string `json:"citation_style,omitempty"`
 }
 
 //
@@ -35,9 +50,14 @@ type Post struct {
 	Name        string
 	Series      *Series
 	Title       string
+	Updated     time.Time // zero if never revised (or not known to be)
+
+	metadataPath string
+	index        *PostIndex // For resolving wiki links; nil until indexed.
 }
 
-func NewPost(dateStr, name, contentPath, metadataPath string, series *Series) (*Post, error) {
+// Returns a new post, with typography applied to its title.
+func NewPost(dateStr, name, contentPath, metadataPath string, series *Series, typography Typography) (*Post, error) {
 	t, err := time.Parse(IsoDateFormat, dateStr)
 	if err != nil {
 		return nil, err
@@ -48,17 +68,19 @@ func NewPost(dateStr, name, contentPath, metadataPath string, series *Series) (*
 	if err != nil {
 		return nil, err
 	}
+	typeset(doc, typography)
 	title := getTitle(doc)
 	if title == "" {
 		return nil, fmt.Errorf("No title found for %s", contentPath)
 	}
 
 	post := &Post{
-		Date:        t,
-		Name:        name,
-		ContentPath: contentPath,
-		Title:       title,
-		Series:      series,
+		Date:         t,
+		Name:         name,
+		ContentPath:  contentPath,
+		Title:        title,
+		Series:       series,
+		metadataPath: metadataPath,
 	}
 
 	if metadataPath != "" {
@@ -68,29 +90,198 @@ func NewPost(dateStr, name, contentPath, metadataPath string, series *Series) (*
 		}
 		err = json.Unmarshal(b, &post.Metadata)
 		if err != nil {
-			return nil, err
 			return nil, fmt.Errorf("NewPost error: %w", err)
 		}
 	}
 
+	if post.Metadata.Updated != "" {
+		post.Updated, err = parseUpdated(post.Metadata.Updated)
+		if err != nil {
+			return nil, fmt.Errorf("NewPost error: %s: %w", metadataPath, err)
+		}
+	}
+
 	return post, nil
 }
 
+// Returns when the post was last updated, or else when it was posted.
+func (p *Post) LastModified() time.Time {
+	if p.Updated.IsZero() {
+		return p.Date
+	}
+	return p.Updated
+}
+
+// Parses the post, expanding includes and shortcodes and replacing wiki
+// links and citations with links to what they refer to, and applying
+// typography. Returns the document even if some don't resolve, along
+// with an error saying so.
+func (p *Post) parse() (ast.Node, error) {
+	doc, err := p.parseExpanded()
+	if err != nil {
+		return nil, err
+	}
+	replaceCallouts(doc)
+	if p.index != nil {
+		err = p.index.replaceWikiLinks(doc)
+	} else if links := findWikiLinks(doc); len(links) > 0 {
+		err = fmt.Errorf("unresolved wiki link(s): post not indexed")
+	}
+	if citeErr := p.replaceCitations(doc); err == nil {
+		err = citeErr
+	}
+	typeset(doc, p.typography())
+	if err != nil {
+		err = fmt.Errorf("%s: %w", p.ContentPath, err)
+	}
+	return doc, err
+}
+
+// Parses the post's markdown with its includes and shortcodes expanded,
+// but resolving nothing.
+func (p *Post) parseExpanded() (ast.Node, error) {
+	src, err := ioutil.ReadFile(p.ContentPath)
+	if err != nil {
+		return nil, err
+	}
+	if p.index == nil {
+		return newParser().Parse(src), nil
+	}
+	stack := []string{p.ContentPath}
+	expanded, _, err := expandIncludes(src, stack, p.index.snippetsDir)
+	if err != nil {
+		return nil, err // Already says where.
+	}
+	out, codes, err := expandShortcodes(expanded, p.index.shortcodes, p, nil)
+	var doc ast.Node
+	if err == nil {
+		doc = newParser().Parse(out)
+		err = replaceShortcodes(doc, codes)
+	}
+	var lineErr *shortcodeError
+	if errors.As(err, &lineErr) {
+		// Say where in the post or the files it includes.
+		path, line := includedLine(src, stack, p.index.snippetsDir, lineErr.line)
+		return nil, fmt.Errorf("%s: line %d: %w", path, line, lineErr.err)
+	}
+	return doc, err
+}
+
+// Returns whether the post's footnotes are rendered as sidenotes.
+func (p *Post) Sidenotes() bool {
+	if p.Metadata.Sidenotes != nil {
+		return *p.Metadata.Sidenotes
+	}
+	return p.index != nil && p.index.opts.Sidenotes
+}
+
+// Returns how the post cites references.
+func (p *Post) CitationStyle() string {
+	switch {
+	case p.Metadata.CitationStyle != "":
+		return p.Metadata.CitationStyle
+	case p.index != nil && p.index.opts.CitationStyle != "":
+		return p.index.opts.CitationStyle
+	}
+	return CitationsNumeric
+}
+
+// Replaces citations in a post's document, from the references in its
+// directory. A post without references has no citations, so bracketed
+// text like [cc @alice] is left alone (though check reports it).
+func (p *Post) replaceCitations(doc ast.Node) error {
+	path := findReferences(filepath.Dir(p.ContentPath))
+	if path == "" {
+		return nil
+	}
+	entries, err := bib.Load(path)
+	if err != nil {
+		return err
+	}
+	return replaceCitations(doc, entries, p.CitationStyle())
+}
+
+// Returns whether the post's math is rendered as MathML.
+func (p *Post) MathML() bool {
+	return p.index != nil && p.index.opts.MathML
+}
+
+// Returns the typographic transforms applied to the post.
+func (p *Post) typography() Typography {
+	if p.index == nil {
+		return TypographyNone
+	}
+	return p.index.opts.Typography
+}
+
+func (p *Post) renderOptions() renderOptions {
+	return renderOptions{
+		sidenotes:  p.Sidenotes(),
+		mathml:     p.MathML(),
+		typography: p.typography(),
+	}
+}
+
 func (p *Post) HTML() (template.HTML, error) {
-	doc, err := parseMarkdown(p.ContentPath)
+	doc, err := p.parse()
 	if err != nil {
 		return template.HTML(""), err
 	}
-	if doc == nil {
-		panic("wat")
-	}
-	output := markdown.Render(doc, mdrenderer)
+	output := markdown.Render(doc, newRenderer(p.renderOptions()))
 	if len(output) == 0 {
 		return template.HTML(""), errors.New("Failed to render document")
 	}
 	return template.HTML(output), nil
 }
 
+// Returns the post's text without markup or title, one paragraph per
+// line.
+func (p *Post) PlainText() (string, error) {
+	doc, err := p.parse()
+	if doc == nil {
+		return "", err
+	}
+	return plainText(doc), nil // Unresolved wiki links are fine here.
+}
+
+// Returns the paths of the files the post includes.
+func (p *Post) includes() ([]string, error) {
+	if p.index == nil {
+		return nil, nil
+	}
+	src, err := ioutil.ReadFile(p.ContentPath)
+	if err != nil {
+		return nil, err
+	}
+	_, paths, err := expandIncludes(src, []string{p.ContentPath}, p.index.snippetsDir)
+	return paths, err
+}
+
+// Returns the newest modification time of the files the post is rendered
+// from, including any it includes.
+func (p *Post) ModTime() (time.Time, error) {
+	var newest time.Time
+	includes, err := p.includes()
+	if err != nil {
+		return time.Time{}, err
+	}
+	referencesPath := findReferences(filepath.Dir(p.ContentPath))
+	paths := append([]string{p.ContentPath, p.metadataPath, referencesPath}, includes...)
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		st, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if st.ModTime().After(newest) {
+			newest = st.ModTime()
+		}
+	}
+	return newest, nil
+}
+
 func (p *Post) RelativeURL() string {
 	if p.Series.Name == "" {
 		return path.Join("/posts", p.Name) + "/"
@@ -172,6 +363,14 @@ type PostIndex struct {
 	seriesMap map[string]*Series
 	Posts     []*Post
 	Series    []*Series
+	// All posts by year and month, newest first.
+	Years      []*Year
+	related    map[*Post][]*Post
+	backlinks  map[*Post][]*Post
+	shortcodes *template.Template
+	opts       *Options
+	// Where {{< include snippet="name" >}} finds name.md.
+	snippetsDir string
 }
 
 func (p *PostIndex) Get(series, name string) *Post {
@@ -211,6 +410,16 @@ func (p *PostIndex) GetPriorPosts(seriesName string) []*Post {
 	}
 }
 
+// Returns the posts most related to a post, most related first.
+func (p *PostIndex) GetRelated(post *Post) []*Post {
+	return p.related[post]
+}
+
+// Returns the posts that link to a post with wiki links, newest first.
+func (p *PostIndex) GetBacklinks(post *Post) []*Post {
+	return p.backlinks[post]
+}
+
 // Returns the base (unnamed) series. For now, that's all
 // the random-access we need.
 func (p *PostIndex) GetBaseSeries() *Series {
@@ -229,7 +438,7 @@ const (
 // Reads posts within a posts/ directory including down one layer for
 // named series.
 //
-func readPosts(postsDir, seriesName string) ([]*Post, []*Series, error) {
+func readPosts(postsDir, seriesName string, opts *Options) ([]*Post, []*Series, error) {
 	d, err := os.Open(postsDir)
 	if err != nil {
 		return nil, nil, err
@@ -302,10 +511,14 @@ func readPosts(postsDir, seriesName string) ([]*Post, []*Series, error) {
 			// Parse the post
 			basename := filepath.Base(info.Name())
 			if m := postRegexp.FindStringSubmatch(basename); m != nil {
-				post, err := NewPost(m[1], m[2], contentPath, metadataPath, series)
+				post, err := NewPost(m[1], m[2], contentPath, metadataPath, series,
+					opts.Typography)
 				if err != nil {
 					return nil, nil, err
 				}
+				if post.Updated.IsZero() {
+					post.Updated = inferUpdated(postDir, post.Date, opts.UpdatedFrom)
+				}
 				posts = append(posts, post)
 				series.Posts = append(series.Posts, post)
 			} else {
@@ -318,7 +531,7 @@ func readPosts(postsDir, seriesName string) ([]*Post, []*Series, error) {
 			// as a series of posts.
 			baseName := info.Name()
 			dir := filepath.Join(d.Name(), baseName)
-			seriesPosts, seriesSlice, err := readPosts(dir, baseName)
+			seriesPosts, seriesSlice, err := readPosts(dir, baseName, opts)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -340,9 +553,12 @@ func readPosts(postsDir, seriesName string) ([]*Post, []*Series, error) {
 	return posts, allSeries, nil
 }
 
-// Loads posts from a directory into a PostIndex.
-func NewPostIndex(contentDir string) (*PostIndex, error) {
-	posts, series, err := readPosts(filepath.Join(contentDir, "posts"), "")
+// Loads posts from a directory into a PostIndex. opts may be nil.
+func NewPostIndex(contentDir string, opts *Options) (*PostIndex, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	posts, series, err := readPosts(filepath.Join(contentDir, "posts"), "", opts)
 	if err != nil {
 		return nil, err
 	}
@@ -353,11 +569,20 @@ func NewPostIndex(contentDir string) (*PostIndex, error) {
 	// Sort all series
 	sortSeries(series)
 
+	shortcodes, err := loadShortcodes(opts.ShortcodesDir)
+	if err != nil {
+		return nil, err
+	}
+
 	pi := &PostIndex{
-		postMap:   make(map[string]map[string]*Post),
-		seriesMap: make(map[string]*Series),
-		Posts:     posts,
-		Series:    series,
+		postMap:     make(map[string]map[string]*Post),
+		seriesMap:   make(map[string]*Series),
+		Posts:       posts,
+		Series:      series,
+		Years:       groupByDate(posts),
+		shortcodes:  shortcodes,
+		opts:        opts,
+		snippetsDir: filepath.Join(contentDir, "snippets"),
 	}
 
 	// Index series by name
@@ -367,11 +592,35 @@ func NewPostIndex(contentDir string) (*PostIndex, error) {
 
 	// Index posts by series
 	for _, post := range posts {
+		post.index = pi
 		if _, ok := pi.postMap[post.Series.Name]; !ok {
 			pi.postMap[post.Series.Name] = make(map[string]*Post)
 		}
 		pi.postMap[post.Series.Name][post.Name] = post
 	}
 
+	// Warn of math that will be rendered as LaTeX
+	if opts.MathML {
+		for _, post := range posts {
+			warnMath(post)
+		}
+	}
+
+	// Find related posts
+	n := opts.RelatedPosts
+	if n == 0 {
+		n = DefaultRelatedPosts
+	}
+	pi.related, err = findRelated(posts, n)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find posts' backlinks
+	pi.backlinks, err = findBacklinks(pi)
+	if err != nil {
+		return nil, err
+	}
+
 	return pi, nil
 }
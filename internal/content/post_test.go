@@ -137,7 +137,7 @@ func TestPostIndex(t *testing.T) {
 	contentRoot := createContent(t, allSeries)
 	defer deleteContent(contentRoot)
 
-	pi, err := NewPostIndex(contentRoot)
+	pi, err := NewPostIndex(contentRoot, nil)
 	if err != nil {
 		t.Fatalf("NewPostIndex failed: %v", err)
 	}
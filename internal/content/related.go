@@ -0,0 +1,160 @@
+package content
+
+//
+// Finds related posts, by tags, series and similarity of text.
+//
+
+import (
+	"math"
+	"sort"
+
+	"github.com/hblanks/speakwrite/internal/text"
+)
+
+// The default number of related posts found for each post.
+const DefaultRelatedPosts = 3
+
+// How much sharing tags (all of them, scaled by the fraction in common)
+// or a named series counts, compared to having the same text (1).
+const (
+	relatedTagWeight    = 0.5
+	relatedSeriesWeight = 0.25
+)
+
+// A weighted stem in a vector.
+type term struct {
+	stem   string
+	weight float64
+}
+
+// A sparse vector, sorted by stem so that sums over it always add in the
+// same order, and so come out the same to the last bit.
+type vector []term
+
+// Returns the unit TF-IDF vector of each post's stemmed title and text,
+// less stop words.
+func tfidfVectors(posts []*Post) ([]vector, error) {
+	counts := make([]map[string]int, len(posts))
+	df := make(map[string]int)
+	for i, p := range posts {
+		body, err := p.PlainText()
+		if err != nil {
+			return nil, err
+		}
+		counts[i] = make(map[string]int)
+		for _, w := range text.Tokenize(p.Title + "\n" + body) {
+			if text.IsStopWord(w) {
+				continue
+			}
+			stem := text.Stem(w)
+			if counts[i][stem] == 0 {
+				df[stem]++
+			}
+			counts[i][stem]++
+		}
+	}
+
+	n := float64(len(posts))
+	vectors := make([]vector, len(posts))
+	for i, c := range counts {
+		var v vector
+		for stem, count := range c {
+			weight := (1 + math.Log(float64(count))) * math.Log(n/float64(df[stem]))
+			if weight > 0 {
+				v = append(v, term{stem, weight})
+			}
+		}
+		sort.Slice(v, func(a, b int) bool { return v[a].stem < v[b].stem })
+		var norm float64
+		for _, t := range v {
+			norm += t.weight * t.weight
+		}
+		norm = math.Sqrt(norm)
+		for j := range v {
+			v[j].weight /= norm
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+// Returns the dot product of two vectors.
+func dot(a, b vector) float64 {
+	var sum float64
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i].stem < b[j].stem:
+			i++
+		case a[i].stem > b[j].stem:
+			j++
+		default:
+			sum += a[i].weight * b[j].weight
+			i++
+			j++
+		}
+	}
+	return sum
+}
+
+// Returns the fraction of all the tags of a and b that they share.
+func tagOverlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := make(map[string]bool)
+	for _, t := range a {
+		set[t] = true
+	}
+	shared := 0
+	for _, t := range b {
+		if set[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// Returns up to n related posts for each post, most related first.
+// posts must be sorted newest first, which breaks ties.
+func findRelated(posts []*Post, n int) (map[*Post][]*Post, error) {
+	related := make(map[*Post][]*Post)
+	if n <= 0 || len(posts) < 2 {
+		return related, nil
+	}
+	vectors, err := tfidfVectors(posts)
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		index int
+		score float64
+	}
+	for i, p := range posts {
+		var candidates []candidate
+		for j, q := range posts {
+			if i == j {
+				continue
+			}
+			score := dot(vectors[i], vectors[j]) +
+				relatedTagWeight*tagOverlap(p.Metadata.Tags, q.Metadata.Tags)
+			if p.Series.Name != "" && p.Series == q.Series {
+				score += relatedSeriesWeight
+			}
+			if score > 0 {
+				candidates = append(candidates, candidate{j, score})
+			}
+		}
+		// Stable, so that ties stay newest first.
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return candidates[a].score > candidates[b].score
+		})
+		if len(candidates) > n {
+			candidates = candidates[:n]
+		}
+		for _, c := range candidates {
+			related[p] = append(related[p], posts[c.index])
+		}
+	}
+	return related, nil
+}
@@ -0,0 +1,84 @@
+package content
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindRelated(t *testing.T) {
+	dir := t.TempDir()
+	base := &Series{}
+	rust := &Series{Name: "rust"}
+	var posts []*Post
+	for i, p := range []struct {
+		name, body string
+		series     *Series
+		tags       []string
+	}{
+		// Newest first.
+		{"gardening", "Tomatoes and roses in the garden.", base, nil},
+		{"rust-traits", "Traits describe shared behavior.", rust, []string{"rust"}},
+		{"go-errors", "Errors are values. Wrapping errors adds context.", base, []string{"go"}},
+		{"rust-errors", "Rust errors use Result, and wrapping errors adds context.", rust, []string{"rust"}},
+		{"go-http", "An HTTP server in Go.", base, []string{"go", "http"}},
+	} {
+		path := filepath.Join(dir, p.name+".md")
+		if err := os.WriteFile(path, []byte(p.body+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		posts = append(posts, &Post{
+			Name:        p.name,
+			Date:        time.Date(2020, 1, 10-i, 0, 0, 0, 0, time.UTC),
+			ContentPath: path,
+			Series:      p.series,
+			Metadata:    PostMetadata{Tags: p.tags},
+		})
+	}
+
+	related, err := findRelated(posts, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(posts []*Post) []string {
+		var names []string
+		for _, p := range posts {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+	for i, expected := range [][]string{
+		nil,
+		{"rust-errors"},
+		{"rust-errors", "go-http"},
+		{"rust-traits", "go-errors"},
+		{"go-errors"},
+	} {
+		if actual := names(related[posts[i]]); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("related to %s: %q, not %q", posts[i].Name, actual, expected)
+		}
+	}
+
+	if related, _ := findRelated(posts, 0); len(related) != 0 {
+		t.Errorf("found related posts with n=0")
+	}
+
+	// Scores are summed in the same order every time, so ties break the
+	// same way.
+	first, err := tfidfVectors(posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for run := 0; run < 10; run++ {
+		vectors, _ := tfidfVectors(posts)
+		for i := range vectors {
+			for j := range vectors {
+				if a, b := dot(vectors[i], vectors[j]), dot(first[j], first[i]); a != b {
+					t.Fatalf("dot() of posts %d and %d is %v, then %v", i, j, b, a)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package content
+
+//
+// Creates new, empty posts.
+//
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Returns a lowercase, hyphen-separated name for a title, suitable for
+// use in URLs. E.g. "Hello, World!" becomes "hello-world". Letters
+// outside ASCII are kept as they are, so "Café" becomes "café".
+func Slugify(title string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r == '\'' || r == '’':
+			// Drop apostrophes rather than splitting words on them.
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		default:
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
+// Options for a new post.
+type NewPostOptions struct {
+	Title  string
+	Series string // empty for the base series
+	Tags   []string
+	Date   time.Time
+}
+
+// Returns the names of the posts and series within a posts/ directory.
+func existingNames(dir string) (posts, series map[string]string, err error) {
+	posts = make(map[string]string)
+	series = make(map[string]string)
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return posts, series, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		p := filepath.Join(dir, info.Name())
+		if m := postRegexp.FindStringSubmatch(info.Name()); m != nil {
+			posts[m[2]] = p
+		} else {
+			series[info.Name()] = p
+		}
+	}
+	return posts, series, nil
+}
+
+// Creates the directory, index.md and metadata.json for a new post, in
+// the format readPosts expects. Refuses to reuse the name of an existing
+// post in the same series, or (in the base series) of a series. Returns
+// the path to the new index.md.
+func CreatePost(contentDir string, o NewPostOptions) (string, error) {
+	name := Slugify(o.Title)
+	if name == "" {
+		return "", fmt.Errorf("Title %q has no characters usable in a name", o.Title)
+	}
+	if strings.ContainsAny(o.Series, `/\`) || o.Series == "." || o.Series == ".." {
+		return "", fmt.Errorf("Invalid series name %q", o.Series)
+	}
+	if postRegexp.MatchString(o.Series) {
+		return "", fmt.Errorf("Series name %q looks like a post directory", o.Series)
+	}
+	if strings.ContainsAny(o.Title, "\n") {
+		return "", errors.New("Title must be a single line")
+	}
+
+	postsDir := filepath.Join(contentDir, "posts")
+	basePosts, allSeries, err := existingNames(postsDir)
+	if err != nil {
+		return "", err
+	}
+	if p, ok := basePosts[o.Series]; ok && o.Series != "" {
+		return "", fmt.Errorf("Series name %q is already a post at %s", o.Series, p)
+	}
+
+	seriesDir := postsDir
+	seriesPosts := basePosts
+	if o.Series != "" {
+		seriesDir = filepath.Join(postsDir, o.Series)
+		if seriesPosts, _, err = existingNames(seriesDir); err != nil {
+			return "", err
+		}
+	} else if p, ok := allSeries[name]; ok {
+		return "", fmt.Errorf("Post name %q is already a series at %s", name, p)
+	}
+	if p, ok := seriesPosts[name]; ok {
+		return "", fmt.Errorf("Post name %q is already used at %s", name, p)
+	}
+
+	date := o.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	postDir := filepath.Join(seriesDir, date.Format(IsoDateFormat)+"-"+name)
+	if err := os.MkdirAll(seriesDir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.Mkdir(postDir, 0755); err != nil {
+		return "", err
+	}
+	contentPath, err := writePost(postDir, o)
+	if err != nil {
+		// Leave nothing half-written for readPosts to trip over.
+		os.RemoveAll(postDir)
+		return "", err
+	}
+	return contentPath, nil
+}
+
+// ioutil.WriteFile, which tests replace to make writes fail.
+var writeFile = ioutil.WriteFile
+
+// Writes the metadata.json and index.md of a new post into its directory,
+// returning the path to index.md.
+func writePost(postDir string, o NewPostOptions) (string, error) {
+	md := PostMetadata{Tags: o.Tags}
+	if md.Tags == nil {
+		md.Tags = []string{}
+	}
+	b, err := json.MarshalIndent(&md, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	metadataPath := filepath.Join(postDir, "metadata.json")
+	if err := writeFile(metadataPath, append(b, '\n'), 0644); err != nil {
+		return "", err
+	}
+
+	contentPath := filepath.Join(postDir, "index.md")
+	data := []byte("% " + o.Title + "\n\n")
+	if err := writeFile(contentPath, data, 0644); err != nil {
+		return "", err
+	}
+	return contentPath, nil
+}
@@ -0,0 +1,87 @@
+package content
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSlugify(t *testing.T) {
+	for title, expected := range map[string]string{
+		"Hello, World!":        "hello-world",
+		"  Don't -- panic  ":   "dont-panic",
+		"HTTP/2 in 10 minutes": "http-2-in-10-minutes",
+		"Café au lait":         "café-au-lait",
+		"Cafe\u0301 crème":     "cafe\u0301-crème",
+		"Привет, мир":          "привет-мир",
+		"!!!":                  "",
+	} {
+		if actual := Slugify(title); actual != expected {
+			t.Errorf("Slugify(%q) = %q, not %q", title, actual, expected)
+		}
+	}
+}
+
+func TestCreatePost(t *testing.T) {
+	root := t.TempDir()
+	date := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	p, err := CreatePost(root, NewPostOptions{
+		Title:  "First Post",
+		Series: "a",
+		Tags:   []string{"foo"},
+		Date:   date,
+	})
+	if err != nil {
+		t.Fatalf("CreatePost() returned unexpected error: %v", err)
+	}
+	expected := filepath.Join(root, "posts", "a", "2020-01-02-first-post", "index.md")
+	if p != expected {
+		t.Errorf("CreatePost() = %s, not %s", p, expected)
+	}
+
+	pi, err := NewPostIndex(root, nil)
+	if err != nil {
+		t.Fatalf("NewPostIndex() of scaffolded post failed: %v", err)
+	}
+	post := pi.Get("a", "first-post")
+	if post == nil || post.Title != "First Post" || post.Metadata.Tags[0] != "foo" {
+		t.Errorf("scaffolded post not indexed as expected: %#v", post)
+	}
+
+	for _, o := range []NewPostOptions{
+		{Title: "First post!", Series: "a", Date: date.AddDate(0, 0, 1)},
+		{Title: "A"},
+		{Title: "Second", Series: "../x"},
+		{Title: "???"},
+	} {
+		if _, err := CreatePost(root, o); err == nil {
+			t.Errorf("CreatePost(%+v) did not fail", o)
+		}
+	}
+}
+
+func TestCreatePostFailedWrite(t *testing.T) {
+	root := t.TempDir()
+	defer func() { writeFile = ioutil.WriteFile }()
+	writeFile = func(path string, data []byte, perm os.FileMode) error {
+		if filepath.Base(path) == "index.md" {
+			return errors.New("disk full")
+		}
+		return ioutil.WriteFile(path, data, perm)
+	}
+
+	if _, err := CreatePost(root, NewPostOptions{Title: "Doomed"}); err == nil {
+		t.Fatal("CreatePost() did not fail")
+	}
+	infos, err := ioutil.ReadDir(filepath.Join(root, "posts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 0 {
+		t.Errorf("CreatePost() left %s behind", infos[0].Name())
+	}
+}
@@ -0,0 +1,431 @@
+package content
+
+//
+// Shortcodes: {{< name key="value" >}} in a post, or
+// {{< name >}}inner markdown{{< /name >}}, which are replaced before
+// parsing by the output of the template THEME_DIR/shortcodes/name.html.
+// Inner markdown is parsed with the rest of the post, and the template's
+// output is rendered around it.
+//
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+)
+
+var (
+	shortcodeRegexp = regexp.MustCompile(
+		`\{\{<\s*(/?)([\w-]+)((?:\s+[\w-]+=(?:"[^"]*"|'[^']*'|[^\s"'>]+))*)\s*>\}\}`)
+	shortcodeParamRegexp = regexp.MustCompile(
+		`([\w-]+)=(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	listItemRegexp = regexp.MustCompile(`^ {0,3}(?:[-*+]|\d+[.)])[ \t]`)
+)
+
+// What a shortcode template gets as .Inner while expanding, to be split
+// on for the rendered inner markdown.
+const innerMarker = "\x00inner\x00"
+
+// A shortcode wrapping markdown, whose blocks are its children.
+type Shortcode struct {
+	ast.Container
+
+	Name string
+	// The template's output, split where it has the inner markdown; or,
+	// for a shortcode wrapping nothing, just the output.
+	Parts []string
+
+	line        int  // Of the opening tag, in the markdown it's expanded in.
+	selfClosing bool // Whether it wraps nothing, and so has no closing tag.
+}
+
+// Returns the comments that mark where shortcode i's inner markdown
+// starts and ends once expanded, or where shortcode i is if it wraps
+// nothing. They're only punctuation, so that ids made from the text of a
+// heading with a shortcode in it are as if it weren't there.
+func shortcodeMarkers(i int) (string, string) {
+	n := strings.Map(func(r rune) rune {
+		return rune(".,:;!?+=~^"[r-'0'])
+	}, strconv.Itoa(i))
+	return "<!--{" + n + "}-->", "<!--{/" + n + "}-->"
+}
+
+// The data a shortcode template is executed with. Its output is part of
+// the post, so relative URLs in it (like src="cat.jpg") resolve against
+// the post's directory.
+type ShortcodeData struct {
+	Post   *Post
+	Params map[string]string
+	// The markdown between opening and closing tags, rendered.
+	Inner template.HTML
+}
+
+// An error in a shortcode, at a line of the markdown it's expanded in.
+type shortcodeError struct {
+	line int
+	err  error
+}
+
+func (e *shortcodeError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.line, e.err)
+}
+
+func (e *shortcodeError) Unwrap() error {
+	return e.err
+}
+
+// Loads the shortcode templates in dir. Returns nil if dir is "" or
+// doesn't exist.
+func loadShortcodes(dir string) (*template.Template, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+	t, err := template.ParseFiles(paths...)
+	if err != nil {
+		return nil, fmt.Errorf("shortcodes error: %w", err)
+	}
+	return t, nil
+}
+
+// Returns whether a line is indented enough to be code.
+func isIndented(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("    ")) || bytes.HasPrefix(line, []byte("\t"))
+}
+
+// Returns the ranges of src in code blocks (fenced or indented) or code
+// spans, where shortcodes are left alone.
+func codeRanges(src []byte) [][2]int {
+	var ranges [][2]int
+	var fence string
+	fenceStart := 0
+	textStart := 0 // Of text outside code blocks, to look for spans in.
+	blank, inCode, inList := true, false, false
+	addBlock := func(start, end int) {
+		ranges = append(ranges, codeSpans(src, textStart, start)...)
+		ranges = append(ranges, [2]int{start, end})
+		textStart = end
+	}
+	for offset := 0; offset < len(src); {
+		end := bytes.IndexByte(src[offset:], '\n')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += offset + 1
+		}
+		raw := src[offset:end]
+		line := strings.TrimSpace(string(raw))
+		switch {
+		case fence != "":
+			if strings.HasPrefix(line, fence) {
+				addBlock(fenceStart, end)
+				fence = ""
+			}
+		case line == "":
+			// Blank lines don't end indented code or lists.
+		case isIndented(raw) && (inCode || blank && !inList):
+			// Indented code, unless it continues a paragraph or list item.
+			addBlock(offset, end)
+			inCode = true
+		case strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~"):
+			fence, fenceStart = line[:3], offset
+			inCode, inList = false, false
+		default:
+			inCode = false
+			if listItemRegexp.Match(raw) {
+				inList = true
+			} else if !isIndented(raw) && blank {
+				inList = false
+			}
+		}
+		blank = line == ""
+		offset = end
+	}
+	if fence != "" {
+		addBlock(fenceStart, len(src))
+	} else {
+		ranges = append(ranges, codeSpans(src, textStart, len(src))...)
+	}
+	return ranges
+}
+
+// Returns the ranges of code spans in src[start:end]: text between runs
+// of the same number of backticks, so a span of two may hold a single
+// backtick.
+func codeSpans(src []byte, start, end int) [][2]int {
+	var ranges [][2]int
+	runEnd := func(i int) int {
+		for i < end && src[i] == '`' {
+			i++
+		}
+		return i
+	}
+	for i := start; i < end; {
+		if src[i] != '`' {
+			i++
+			continue
+		}
+		open := runEnd(i)
+		n := open - i
+		closed := false
+		for j := open; j < end; {
+			if src[j] != '`' {
+				j++
+				continue
+			}
+			k := runEnd(j)
+			if k-j == n {
+				ranges = append(ranges, [2]int{i, k})
+				i, closed = k, true
+				break
+			}
+			j = k
+		}
+		if !closed {
+			i = open // Backticks without a match are literal.
+		}
+	}
+	return ranges
+}
+
+// Returns whether offset i is within any of ranges.
+func inRanges(ranges [][2]int, i int) bool {
+	for _, r := range ranges {
+		if i >= r[0] && i < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the shortcode tags in src, outside code, as submatch indexes of
+// shortcodeRegexp.
+func findShortcodes(src []byte) [][]int {
+	code := codeRanges(src)
+	var tags [][]int
+	for _, m := range shortcodeRegexp.FindAllSubmatchIndex(src, -1) {
+		if !inRanges(code, m[0]) {
+			tags = append(tags, m)
+		}
+	}
+	return tags
+}
+
+// Parses a shortcode's parameters, like key="value".
+func shortcodeParams(src []byte) map[string]string {
+	params := make(map[string]string)
+	for _, p := range shortcodeParamRegexp.FindAllSubmatch(src, -1) {
+		params[string(p[1])] = string(p[2]) + string(p[3]) + string(p[4])
+	}
+	return params
+}
+
+// Returns the text between the start of the line that offset i is on
+// and i.
+func linePrefix(src []byte, i int) []byte {
+	return src[bytes.LastIndexByte(src[:i], '\n')+1 : i]
+}
+
+// Expands the shortcodes in src, a post's markdown, appending them to
+// codes for replaceShortcodes. A shortcode wrapping markdown is expanded
+// to that markdown, as blocks between a pair of shortcodeMarkers; any
+// other, to the first of its markers, so that its template's output isn't
+// parsed as markdown.
+func expandShortcodes(src []byte, t *template.Template, post *Post,
+	codes []*Shortcode) ([]byte, []*Shortcode, error) {
+	tags := findShortcodes(src)
+
+	var out bytes.Buffer
+	last := 0
+	for i := 0; i < len(tags); i++ {
+		m := tags[i]
+		name := string(src[m[4]:m[5]])
+		line := lineAt(src, m[0])
+		if m[3] > m[2] {
+			return nil, nil, &shortcodeError{line,
+				fmt.Errorf("closing shortcode %q without opening", name)}
+		}
+		if t == nil || t.Lookup(name+".html") == nil {
+			return nil, nil, &shortcodeError{line, fmt.Errorf("unknown shortcode %q", name)}
+		}
+		data := ShortcodeData{Post: post, Params: shortcodeParams(src[m[6]:m[7]])}
+
+		// Look for a closing tag.
+		closing := -1
+		for j := i + 1; j < len(tags); j++ {
+			c := tags[j]
+			if c[3] > c[2] && string(src[c[4]:c[5]]) == name {
+				closing = j
+				break
+			}
+		}
+
+		if closing >= 0 {
+			data.Inner = innerMarker
+		}
+		var output strings.Builder
+		if err := t.ExecuteTemplate(&output, name+".html", &data); err != nil {
+			return nil, nil, &shortcodeError{line, fmt.Errorf("shortcode %q: %w", name, err)}
+		}
+		code := &Shortcode{
+			Name:        name,
+			Parts:       []string{output.String()},
+			line:        line,
+			selfClosing: closing < 0,
+		}
+		codes = append(codes, code)
+		openMarker, closeMarker := shortcodeMarkers(len(codes) - 1)
+
+		out.Write(src[last:m[0]])
+		if code.selfClosing {
+			out.WriteString(openMarker)
+			last = m[1]
+			continue
+		}
+
+		// Escaped, .Inner wouldn't split, so must be in text once.
+		code.Parts = strings.Split(code.Parts[0], innerMarker)
+		if len(code.Parts) != 2 {
+			return nil, nil, &shortcodeError{line, fmt.Errorf(
+				"shortcode %q: .Inner must appear exactly once, in HTML text context", name)}
+		}
+
+		c := tags[closing]
+
+		var inner []byte
+		var err error
+		inner, codes, err = expandShortcodes(src[m[1]:c[0]], t, post, codes)
+		if err != nil {
+			var lineErr *shortcodeError
+			if errors.As(err, &lineErr) {
+				lineErr.line += lineAt(src, m[1]) - 1 // From the inner markdown's.
+			}
+			return nil, nil, err
+		}
+
+		// The inner markdown goes on lines of its own, indented like the
+		// opening tag, so that it's parsed as blocks.
+		indent := linePrefix(src, m[0])
+		if len(bytes.TrimSpace(indent)) > 0 {
+			out.WriteString("\n\n")
+			indent = nil
+		}
+		out.WriteString(openMarker + "\n\n")
+		out.Write(indent)
+		out.Write(bytes.TrimSpace(inner))
+		out.WriteString("\n\n")
+		out.Write(indent)
+		out.WriteString(closeMarker)
+		rest := src[c[1]:]
+		if end := bytes.IndexByte(rest, '\n'); end >= 0 {
+			rest = rest[:end]
+		}
+		if len(bytes.TrimSpace(rest)) > 0 {
+			out.WriteString("\n\n")
+		}
+		last = c[1]
+		i = closing
+	}
+	out.Write(src[last:])
+	return out.Bytes(), codes, nil
+}
+
+// Replaces the blocks between each pair of shortcodeMarkers in a document
+// with the Shortcode they're the inner markdown of, and the marker of each
+// shortcode wrapping nothing with its template's output, as raw HTML.
+func replaceShortcodes(doc ast.Node, codes []*Shortcode) error {
+	if len(codes) == 0 {
+		return nil
+	}
+	markers := make(map[string]ast.Node)
+	var raw []ast.Node // HTML that may have markers within it.
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node.(type) {
+		case *ast.HTMLBlock, *ast.HTMLSpan:
+			if entering {
+				markers[string(bytes.TrimSpace(node.AsLeaf().Literal))] = node
+				raw = append(raw, node)
+			}
+		}
+		return ast.GoToNext
+	})
+	for i, code := range codes {
+		openMarker, closeMarker := shortcodeMarkers(i)
+		if code.selfClosing {
+			replaceSelfClosing(markers[openMarker], raw, openMarker, code.Parts[0])
+			continue
+		}
+		start, end := markers[openMarker], markers[closeMarker]
+		if start == nil || end == nil || start.GetParent() != end.GetParent() {
+			return &shortcodeError{code.line,
+				fmt.Errorf("shortcode %q must open and close in the same block", code.Name)}
+		}
+		parent := start.GetParent()
+		var children []ast.Node
+		in := false
+		for _, child := range parent.GetChildren() {
+			switch {
+			case child == start:
+				in = true
+				code.SetParent(parent)
+				children = append(children, code)
+			case child == end:
+				in = false
+			case in:
+				child.SetParent(code)
+				code.Children = append(code.Children, child)
+			default:
+				children = append(children, child)
+			}
+		}
+		parent.SetChildren(children)
+	}
+	return nil
+}
+
+// Replaces the marker of a shortcode wrapping nothing with output: node,
+// if the marker was parsed as a node of its own, or else the marker
+// within raw HTML around it.
+func replaceSelfClosing(node ast.Node, raw []ast.Node, marker, output string) {
+	switch node.(type) {
+	case *ast.HTMLBlock:
+		replaceNode(node, []ast.Node{&ast.HTMLBlock{Leaf: ast.Leaf{Literal: []byte(output)}}})
+		return
+	case *ast.HTMLSpan:
+		replaceNode(node, []ast.Node{&ast.HTMLSpan{Leaf: ast.Leaf{Literal: []byte(output)}}})
+		return
+	}
+	for _, node := range raw {
+		leaf := node.AsLeaf()
+		leaf.Literal = bytes.Replace(leaf.Literal, []byte(marker), []byte(output), 1)
+	}
+}
+
+// Renders a shortcode as its template's output, with its inner markdown
+// rendered where the template has .Inner.
+func renderShortcode(w io.Writer, r *html.Renderer, s *Shortcode) {
+	var inner bytes.Buffer
+	render := ast.NodeVisitorFunc(func(node ast.Node, entering bool) ast.WalkStatus {
+		return r.RenderNode(&inner, node, entering)
+	})
+	for _, child := range s.Children {
+		ast.Walk(child, render)
+	}
+	renderHTMLBlock(w, r, strings.Join(s.Parts, inner.String()))
+}
@@ -0,0 +1,158 @@
+package content
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShortcodes(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"theme/shortcodes/figure.html": `<figure><img src="{{.Params.src}}" alt="{{.Params.alt}}">` +
+			`<figcaption>{{.Params.caption}}</figcaption></figure>`,
+		"theme/shortcodes/note.html":      `<div class="note">{{.Inner}}</div>`,
+		"theme/shortcodes/em.html":        `<span>{{.Params.t}}</span>`,
+		"posts/2020-01-01-other/index.md": "% Other\n\nText\n",
+		"posts/2020-01-01-figures/index.md": "% Figures\n\n" +
+			"{{< figure src=\"cat.jpg\" alt='A cat' caption=\"Cats & dogs\" >}}\n\n" +
+			"{{< note >}}\nSome *inner* text.\n{{< /note >}}\n\n" +
+			"{{< note >}}See [[other]].{{< /note >}}\n\n" +
+			"Said {{< em t=\"a *b* c_d_e\" >}} inline\n\n" +
+			"Not in `{{< figure >}}` spans\n\n" +
+			"```\n{{< nor in code >}}\n```\n\n" +
+			"Nor in ``{{< double >}}`` spans\n\n" +
+			"    {{< nor in indented code >}}\n",
+	})
+	pi, err := NewPostIndex(root, &Options{
+		ShortcodesDir: root + "/theme/shortcodes",
+	})
+	if err != nil {
+		t.Fatalf("NewPostIndex failed: %v", err)
+	}
+
+	html, err := pi.Get("", "figures").HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`<figure><img src="cat.jpg" alt="A cat"><figcaption>Cats &amp; dogs</figcaption></figure>`,
+		`<div class="note"><p>Some <em>inner</em> text.</p>` + "\n</div>",
+		`<div class="note"><p>See <a href="/posts/other/">Other</a>.</p>` + "\n</div>",
+		`<p>Said <span>a *b* c_d_e</span> inline</p>`,
+		`<code>{{&lt; figure &gt;}}</code>`,
+		`{{&lt; nor in code &gt;}}`,
+		`<code>{{&lt; double &gt;}}</code>`,
+		`<pre><code>{{&lt; nor in indented code &gt;}}`,
+	} {
+		if !strings.Contains(string(html), expected) {
+			t.Errorf("HTML() returned\n%s\nnot containing\n%s", html, expected)
+		}
+	}
+
+	// Inner markdown is parsed like the rest of the post.
+	writeFiles(t, root, map[string]string{
+		"posts/2020-01-01-other/index.md": "% Other\n\n{{< note >}}See [[nowhere]].{{< /note >}}\n",
+	})
+	pi, err = NewPostIndex(root, &Options{
+		ShortcodesDir: root + "/theme/shortcodes",
+	})
+	if err != nil {
+		t.Fatalf("NewPostIndex failed: %v", err)
+	}
+	_, err = pi.Get("", "other").HTML()
+	if err == nil || !strings.Contains(err.Error(),
+		`2020-01-01-other/index.md: unresolved wiki link(s): [[nowhere]]`) {
+		t.Errorf("HTML() returned error %v", err)
+	}
+	if backlinks := pi.GetBacklinks(pi.Get("", "other")); len(backlinks) != 1 {
+		t.Errorf("GetBacklinks() returned %v", backlinks)
+	}
+
+	// Posts are parsed as they're loaded, so errors surface then.
+	writeFiles(t, root, map[string]string{
+		"posts/2020-01-02-unknown/index.md": "% Unknown\n\nText\n\n{{< video id=1 >}}\n",
+	})
+	_, err = NewPostIndex(root, &Options{
+		ShortcodesDir: root + "/theme/shortcodes",
+	})
+	if err == nil || !strings.Contains(err.Error(),
+		`2020-01-02-unknown/index.md: line 5: unknown shortcode "video"`) {
+		t.Errorf("NewPostIndex returned error %v", err)
+	}
+}
+
+func TestShortcodeInnerSharesPost(t *testing.T) {
+	// Inner markdown is part of the post: citations are numbered with the
+	// post's, and heading and footnote ids are unique across both.
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"theme/shortcodes/aside.html":           `<aside>{{.Inner}}</aside>`,
+		"posts/2020-01-01-cites/references.bib": "@book{a,\n  title = {A}\n}\n@book{b,\n  title = {B}\n}\n",
+		"posts/2020-01-01-cites/index.md": "% Cites\n\n## Intro\n\nAs [@a] says.[^1]\n\n" +
+			"{{< aside >}}\n## Intro\n\nAnd [@b], or [@a].[^2]\n{{< /aside >}}\n\n" +
+			"[^1]: One.\n[^2]: Two.\n",
+	})
+	pi, err := NewPostIndex(root, &Options{
+		ShortcodesDir: root + "/theme/shortcodes",
+	})
+	if err != nil {
+		t.Fatalf("NewPostIndex failed: %v", err)
+	}
+	html, err := pi.Get("", "cites").HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`<h2 id="intro">Intro`,
+		"<aside>\n<h2 id=\"intro-1\">Intro",
+		`And <span class="citation">[<a href="#ref-b">2</a>]</span>, ` +
+			`or <span class="citation">[<a href="#ref-a">1</a>]</span>.`,
+		`<li id="ref-a">`,
+		`<li id="ref-b">`,
+		`<li id="fn:2">`,
+		"</aside>\n",
+	} {
+		if !strings.Contains(string(html), expected) {
+			t.Errorf("HTML() returned\n%s\nnot containing\n%s", html, expected)
+		}
+	}
+	if n := strings.Count(string(html), "<h2>References</h2>"); n != 1 {
+		t.Errorf("HTML() has %d bibliographies, not 1:\n%s", n, html)
+	}
+}
+
+func TestShortcodeInnerInAttribute(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"theme/shortcodes/tip.html":     `<span title="{{.Inner}}">?</span>`,
+		"posts/2020-01-01-tip/index.md": "% Tip\n\n{{< tip >}}\nHover text.\n{{< /tip >}}\n",
+	})
+	_, err := NewPostIndex(root, &Options{
+		ShortcodesDir: root + "/theme/shortcodes",
+	})
+	if err == nil || !strings.Contains(err.Error(),
+		`index.md: line 3: shortcode "tip": .Inner must appear exactly once, in HTML text context`) {
+		t.Errorf("NewPostIndex returned error %v", err)
+	}
+}
+
+func TestShortcodeErrorLines(t *testing.T) {
+	// Errors say where in the post, or the files it includes, they are.
+	for expected, post := range map[string]string{
+		`snippets/video.md: line 5: unknown shortcode "video"`: "% In snippet\n\n" +
+			"{{< include snippet=\"video\" >}}\n",
+		`2020-01-01-post/index.md: line 5: unknown shortcode "audio"`: "% After snippet\n\n" +
+			"{{< include snippet=\"intro\" >}}\n\n{{< audio >}}\n",
+	} {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{
+			"snippets/intro.md":              "One\n\nTwo\n\nThree\n",
+			"snippets/video.md":              "One\n\nTwo\n\n{{< video >}}\n",
+			"posts/2020-01-01-post/index.md": post,
+		})
+		_, err := NewPostIndex(root, nil)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("NewPostIndex returned error %v, not %q", err, expected)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package content
+
+//
+// Sidenotes: footnotes repeated in the margin beside their references.
+// The footnote list at the end of the post stays as it is, for themes to
+// show instead on narrow screens.
+//
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+)
+
+// Returns whether a footnote can be rendered inline, i.e. holds only
+// paragraphs or inline content.
+func isInlineFootnote(footnote ast.Node) bool {
+	if footnote == nil {
+		return false
+	}
+	for _, child := range footnote.GetChildren() {
+		switch child.(type) {
+		case *ast.List, *ast.CodeBlock, *ast.BlockQuote, *ast.Table,
+			*ast.HTMLBlock, *ast.Heading, *ast.HorizontalRule, *Callout, *Shortcode:
+			return false
+		}
+	}
+	return true
+}
+
+// Renders a footnote as a sidenote, after its reference. Footnotes with
+// more than paragraphs (e.g. lists or code) have no sidenote, since they
+// can't go inside the paragraph that refers to them.
+func renderSidenote(w io.Writer, r *html.Renderer, link *ast.Link) {
+	if !isInlineFootnote(link.Footnote) {
+		return
+	}
+	render := ast.NodeVisitorFunc(func(node ast.Node, entering bool) ast.WalkStatus {
+		return r.RenderNode(w, node, entering)
+	})
+	io.WriteString(w, `<span class="sidenote" role="note">`+
+		`<span class="sidenote-number">`+strconv.Itoa(link.NoteID)+`</span> `)
+	for i, child := range link.Footnote.GetChildren() {
+		para, ok := child.(*ast.Paragraph)
+		if !ok {
+			ast.Walk(child, render)
+			continue
+		}
+		if i > 0 {
+			io.WriteString(w, "<br>")
+		}
+		for _, inline := range para.GetChildren() {
+			ast.Walk(inline, render)
+		}
+	}
+	io.WriteString(w, "</span>")
+}
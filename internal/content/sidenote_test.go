@@ -0,0 +1,30 @@
+package content
+
+import "testing"
+
+func TestSidenoteGolden(t *testing.T) {
+	testGolden(t, "sidenote", renderOptions{sidenotes: true})
+}
+
+func TestPostSidenotes(t *testing.T) {
+	on, off := true, false
+	for _, tc := range []struct {
+		site     bool
+		post     *bool
+		expected bool
+	}{
+		{false, nil, false},
+		{true, nil, true},
+		{true, &off, false},
+		{false, &on, true},
+	} {
+		post := &Post{
+			Metadata: PostMetadata{Sidenotes: tc.post},
+			index:    &PostIndex{opts: &Options{Sidenotes: tc.site}},
+		}
+		if actual := post.Sidenotes(); actual != tc.expected {
+			t.Errorf("Sidenotes() with site=%v post=%v returned %v",
+				tc.site, tc.post, actual)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package content
+
+//
+// Typographic transforms of a post's text, like curly quotes and dashes,
+// which leave code, math and raw HTML alone.
+//
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// A set of typographic transforms.
+type Typography uint
+
+const (
+	TypographyQuotes   Typography = 1 << iota // "Straight" quotes to “curly” ones.
+	TypographyDashes                          // -- to an en dash, and --- to an em dash.
+	TypographyEllipses                        // ... to an ellipsis.
+	TypographyUnits                           // A non-breaking space in 5 km.
+	TypographyWidows                          // A non-breaking space before a heading's last word.
+
+	TypographyNone Typography = 0
+	TypographyAll             = TypographyQuotes | TypographyDashes |
+		TypographyEllipses | TypographyUnits | TypographyWidows
+)
+
+var typographyNames = map[string]Typography{
+	"quotes":   TypographyQuotes,
+	"dashes":   TypographyDashes,
+	"ellipses": TypographyEllipses,
+	"units":    TypographyUnits,
+	"widows":   TypographyWidows,
+	"all":      TypographyAll,
+	"none":     TypographyNone,
+}
+
+// Parses a comma-separated list of transforms, like "quotes,dashes", or
+// "all" or "none".
+func ParseTypography(s string) (Typography, error) {
+	var t Typography
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		v, ok := typographyNames[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown transform %q", name)
+		}
+		t |= v
+	}
+	return t, nil
+}
+
+// A number, a space, and a unit, which the space shouldn't separate.
+var unitRegexp = regexp.MustCompile(`(\d) ((?:` +
+	`km|cm|mm|µm|μm|nm|m|kg|mg|g|ms|µs|μs|ns|s|min|h|` +
+	`Hz|kHz|MHz|GHz|B|kB|KB|MB|GB|TB|PB|KiB|MiB|GiB|TiB|bps|kbps|Mbps|Gbps|` +
+	`W|kW|MW|V|mV|kV|mA|mAh|°C|°F|K|%|px|em|rem|pt|ft|mi|lb|lbs|oz|L|mL|ml` +
+	`)(?:[^\pL\pN]|$))`)
+
+// Applies transforms to text, remembering the last character across
+// calls so that quotes next to markup, like "*this*", come out right.
+type typesetter struct {
+	t    Typography
+	prev rune // 0 at the start of a block.
+}
+
+// Returns whether a quote after r opens rather than closes.
+func opensQuote(r rune) bool {
+	return r == 0 || unicode.IsSpace(r) || strings.ContainsRune("([{-–—/‘“", r)
+}
+
+// Returns whether s starts with an abbreviated year like 90s or 90.
+func startsYear(s string) bool {
+	return len(s) >= 2 && isDigit(s[0]) && isDigit(s[1]) &&
+		(len(s) == 2 || !isDigit(s[2]))
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func (ts *typesetter) text(s []byte) []byte {
+	var b strings.Builder
+	src := string(s)
+	for i := 0; i < len(src); {
+		rest := src[i:]
+		var out string
+		n := 1
+		switch {
+		case ts.t&TypographyDashes != 0 && strings.HasPrefix(rest, "---"):
+			out, n = "—", 3
+		case ts.t&TypographyDashes != 0 && strings.HasPrefix(rest, "--"):
+			out, n = "–", 2
+		case ts.t&TypographyEllipses != 0 && strings.HasPrefix(rest, "..."):
+			out, n = "…", 3
+		case ts.t&TypographyEllipses != 0 && strings.HasPrefix(rest, ". . ."):
+			out, n = "…", 5
+		case ts.t&TypographyQuotes != 0 && rest[0] == '"':
+			out = "”"
+			if opensQuote(ts.prev) {
+				out = "“"
+			}
+		case ts.t&TypographyQuotes != 0 && rest[0] == '\'':
+			out = "’"
+			if opensQuote(ts.prev) && !startsYear(rest[1:]) {
+				out = "‘"
+			}
+		default:
+			_, n = utf8.DecodeRuneInString(rest)
+			out = rest[:n]
+		}
+		b.WriteString(out)
+		ts.prev, _ = utf8.DecodeLastRuneInString(out)
+		i += n
+	}
+	out := b.String()
+	if ts.t&TypographyUnits != 0 {
+		out = unitRegexp.ReplaceAllString(out, "$1\u00a0$2")
+	}
+	return []byte(out)
+}
+
+// Returns whether a node is inline, and so doesn't start a new run of
+// text for quotes.
+func isInline(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.Text, *ast.Emph, *ast.Strong, *ast.Del, *ast.Link, *ast.Image,
+		*ast.Code, *ast.Math, *ast.HTMLSpan, *ast.Softbreak, *ast.Hardbreak,
+		*ast.NonBlockingSpace, *Citation:
+		return true
+	}
+	return false
+}
+
+// Returns whether a link shows its own destination, as autolinks do.
+func isAutolink(link *ast.Link) bool {
+	text := nodeText(link)
+	dest := string(link.Destination)
+	return text == dest || "mailto:"+text == dest
+}
+
+// Applies typographic transforms to the text of a document, including
+// its title block, but not to code, math, raw HTML or autolinked URLs.
+func typeset(doc ast.Node, t Typography) {
+	if t == TypographyNone {
+		return
+	}
+	ts := &typesetter{t: t}
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !isInline(node) {
+			ts.prev = 0
+		}
+		if !entering {
+			if heading, ok := node.(*ast.Heading); ok && t&TypographyWidows != 0 {
+				preventWidow(heading)
+			}
+			return ast.GoToNext
+		}
+		switch node := node.(type) {
+		case *ast.Link:
+			if isAutolink(node) {
+				ts.prev = 'x'
+				return ast.SkipChildren
+			}
+		case *ast.Text:
+			node.Literal = ts.text(node.Literal)
+		case *ast.Code, *ast.Math, *Citation:
+			ts.prev = 'x' // Like a word, so a quote after it closes.
+		case *ast.Softbreak, *ast.Hardbreak:
+			ts.prev = ' '
+		}
+		return ast.GoToNext
+	})
+}
+
+// Joins the last two words of a heading of three or more words with a
+// non-breaking space, so the last isn't left alone on a line.
+func preventWidow(heading *ast.Heading) {
+	if len(strings.Fields(nodeText(heading))) < 3 {
+		return
+	}
+	var texts []*ast.Text
+	ast.WalkFunc(heading, func(node ast.Node, entering bool) ast.WalkStatus {
+		if text, ok := node.(*ast.Text); ok && entering {
+			texts = append(texts, text)
+		}
+		return ast.GoToNext
+	})
+	for i := len(texts) - 1; i >= 0; i-- {
+		s := string(texts[i].Literal)
+		if j := strings.LastIndexByte(s, ' '); j >= 0 {
+			texts[i].Literal = []byte(s[:j] + "\u00a0" + s[j+1:])
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package content
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown"
+)
+
+func TestParseTypography(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Typography
+		err  bool
+	}{
+		{"", TypographyNone, false},
+		{"quotes, dashes", TypographyQuotes | TypographyDashes, false},
+		{"all", TypographyAll, false},
+		{"quotes,smart", 0, true},
+	} {
+		got, err := ParseTypography(tc.in)
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("ParseTypography(%q) = %v, %v", tc.in, got, err)
+		}
+	}
+}
+
+func TestTypeset(t *testing.T) {
+	for _, tc := range []struct {
+		t        Typography
+		in, want string
+	}{
+		{TypographyQuotes, `"Hi," she said. 'It's the '90s.'`,
+			"<p>“Hi,” she said. ‘It’s the ’90s.’</p>\n"},
+		{TypographyQuotes, `"*Emphatic*" and "` + "`code`" + `"`,
+			"<p>“<em>Emphatic</em>” and “<code>code</code>”</p>\n"},
+		{TypographyDashes | TypographyEllipses, "1--2, yes---no. Wait...",
+			"<p>1–2, yes—no. Wait…</p>\n"},
+		{TypographyUnits, "It ran 5 km in 20 min, at 100 %. 3 in total.",
+			"<p>It ran 5\u00a0km in 20\u00a0min, at 100\u00a0%. 3 in total.</p>\n"},
+		{TypographyWidows, "## A heading of words\n\n## Two words",
+			"<h2 id=\"a-heading-of-words\">A heading of\u00a0words" +
+				anchor("a-heading-of-words") + "</h2>\n\n" +
+				"<h2 id=\"two-words\">Two words" + anchor("two-words") + "</h2>\n"},
+		{TypographyAll, "Run `--help` or <b title=\"x--y\">\"this\"</b>.",
+			"<p>Run <code>--help</code> or <b title=\"x--y\">“this”</b>.</p>\n"},
+		{TypographyAll, "```\n\"quoted\" -- and...\n```",
+			"<pre><code>&quot;quoted&quot; -- and...\n</code></pre>\n"},
+		{TypographyAll, "See <https://example.com/a--b...>.",
+			"<p>See <a href=\"https://example.com/a--b...\">https://example.com/a--b...</a>.</p>\n"},
+	} {
+		doc := newParser().Parse([]byte(tc.in))
+		typeset(doc, tc.t)
+		got := string(markdown.Render(doc, newRenderer(renderOptions{typography: tc.t})))
+		if got != tc.want {
+			t.Errorf("typeset(%q):\ngot:  %q\nwant: %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestTypesetTitle(t *testing.T) {
+	doc := newParser().Parse([]byte("% \"Quoted\" -- and `--code` too\n\nText.\n"))
+	typeset(doc, TypographyAll)
+	want := "“Quoted” – and --code\u00a0too"
+	if got := getTitle(doc); got != want {
+		t.Errorf("getTitle() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package content
+
+//
+// Works out when a post was last updated.
+//
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Parses the "updated" field of post metadata.
+func parseUpdated(s string) (time.Time, error) {
+	if t, err := time.Parse(IsoDateFormat, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(
+			"updated %q is neither an ISO 8601 date nor an RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+// Returns the time of the last commit touching dir, using the git
+// repository on disk.
+func gitUpdated(dir string) (time.Time, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%cI", "--", ".")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, err
+	}
+	s := strings.TrimSpace(string(out))
+	if s == "" {
+		return time.Time{}, nil // Not yet committed.
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// Returns the newest modification time of any file under dir.
+func mtimeUpdated(dir string) (time.Time, error) {
+	var newest time.Time
+	err := filepath.Walk(dir,
+		func(path string, info os.FileInfo, err error) error {
+			switch {
+			case err != nil:
+				return err
+			case info.IsDir():
+				return nil
+			case info.ModTime().After(newest):
+				newest = info.ModTime()
+			}
+			return nil
+		})
+	return newest, err
+}
+
+// Infers when the post in postDir was last updated. Returns the zero time
+// if it can't be inferred, or if the post hasn't changed since the day it
+// was posted.
+func inferUpdated(postDir string, posted time.Time, from string) time.Time {
+	var t time.Time
+	var err error
+	switch from {
+	case UpdatedFromGit:
+		t, err = gitUpdated(postDir)
+	case UpdatedFromMtime:
+		t, err = mtimeUpdated(postDir)
+	default:
+		return time.Time{}
+	}
+	if err != nil {
+		log.Printf("inferUpdated: %s: %v", postDir, err)
+		return time.Time{}
+	}
+	if t.Before(posted.AddDate(0, 0, 1)) {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,83 @@
+package content
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseUpdated(t *testing.T) {
+	for s, expected := range map[string]time.Time{
+		"2021-03-04":                time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+		"2021-03-04T05:06:07Z":      time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC),
+		"2021-03-04T05:06:07-01:00": time.Date(2021, 3, 4, 6, 6, 7, 0, time.UTC),
+	} {
+		actual, err := parseUpdated(s)
+		if err != nil {
+			t.Errorf("parseUpdated(%q) returned unexpected error: %v", s, err)
+		} else if !actual.Equal(expected) {
+			t.Errorf("parseUpdated(%q) = %v, not %v", s, actual, expected)
+		}
+	}
+
+	if _, err := parseUpdated("March 4"); err == nil {
+		t.Errorf("parseUpdated() accepted an invalid date")
+	}
+}
+
+func TestInferUpdated(t *testing.T) {
+	posted := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+
+	if u := inferUpdated(dir, posted, UpdatedFromNone); !u.IsZero() {
+		t.Errorf("inferUpdated() with no source returned %v", u)
+	}
+	if u := inferUpdated(dir, posted, UpdatedFromMtime); !u.IsZero() {
+		t.Errorf("inferUpdated() of an empty dir returned %v", u)
+	}
+}
+
+func TestInferUpdatedFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	posted := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	committed := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"posts/2020-01-01-post/index.md": "% Post\n",
+	})
+	postDir := filepath.Join(root, "posts", "2020-01-01-post")
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@example.com",
+			"GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@example.com",
+			"GIT_AUTHOR_DATE="+committed.Format(time.RFC3339),
+			"GIT_COMMITTER_DATE="+committed.Format(time.RFC3339))
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	// Not yet committed.
+	git("init", "-q")
+	if u := inferUpdated(postDir, posted, UpdatedFromGit); !u.IsZero() {
+		t.Errorf("inferUpdated() of an uncommitted post returned %v", u)
+	}
+
+	git("add", ".")
+	git("commit", "-q", "-m", "Add post")
+	if u := inferUpdated(postDir, posted, UpdatedFromGit); !u.Equal(committed) {
+		t.Errorf("inferUpdated() = %v, not %v", u, committed)
+	}
+
+	// Committed the day it was posted, so not updated.
+	if u := inferUpdated(postDir, committed, UpdatedFromGit); !u.IsZero() {
+		t.Errorf("inferUpdated() of a post committed when posted returned %v", u)
+	}
+}
@@ -0,0 +1,186 @@
+package content
+
+//
+// Wiki-style links between posts: [[name]], [[series/name]], and either
+// with a label, as in [[name|label]].
+//
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+var wikiLinkRegexp = regexp.MustCompile(`\[\[([^\[\]|]+)(?:\|([^\[\]]*))?\]\]`)
+
+type wikiLink struct {
+	target string // As written, e.g. "series/name".
+	label  string // "" if none.
+}
+
+// Returns the series and post names a wiki link target refers to. The
+// series is "" if not given.
+func splitWikiTarget(target string) (series, name string) {
+	target = strings.Trim(strings.TrimSpace(target), "/")
+	if i := strings.LastIndex(target, "/"); i >= 0 {
+		return strings.TrimSpace(target[:i]), strings.TrimSpace(target[i+1:])
+	}
+	return "", target
+}
+
+// Returns the post a wiki link target refers to, or nil. A bare name
+// refers to the post in the base series, or else to the post with that
+// name in another series; it's an error if there's more than one.
+func (p *PostIndex) resolveWikiTarget(target string) (*Post, error) {
+	series, name := splitWikiTarget(target)
+	if series != "" {
+		return p.Get(series, name), nil
+	}
+	if post := p.Get("", name); post != nil {
+		return post, nil
+	}
+	var found *Post
+	var candidates []string
+	for _, s := range p.Series {
+		if post := p.Get(s.Name, name); post != nil {
+			found = post
+			candidates = append(candidates, s.Name)
+		}
+	}
+	if len(candidates) > 1 {
+		return nil, ambiguousWikiLinkError(target, candidates)
+	}
+	return found, nil
+}
+
+// Returns an error for a bare wiki link target that names a post in
+// each of several series.
+func ambiguousWikiLinkError(target string, series []string) error {
+	return fmt.Errorf("wiki link [[%s]] is ambiguous: in series %s",
+		strings.TrimSpace(target), strings.Join(series, ", "))
+}
+
+// Calls fn for each Text node that may contain wiki links, which are
+// those not within links or images.
+func walkWikiText(doc ast.Node, fn func(*ast.Text)) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch node := node.(type) {
+		case *ast.Link, *ast.Image:
+			return ast.SkipChildren
+		case *ast.Text:
+			if entering && wikiLinkRegexp.Match(node.Literal) {
+				fn(node)
+			}
+		}
+		return ast.GoToNext
+	})
+}
+
+// Returns every wiki link in a document.
+func findWikiLinks(doc ast.Node) []wikiLink {
+	var links []wikiLink
+	walkWikiText(doc, func(node *ast.Text) {
+		for _, m := range wikiLinkRegexp.FindAllSubmatch(node.Literal, -1) {
+			links = append(links, wikiLink{string(m[1]), string(m[2])})
+		}
+	})
+	return links
+}
+
+// Replaces wiki links in a document with links to the posts they refer
+// to, labeled with the post's title if the link has no label. Returns an
+// error naming any that don't refer to exactly one post, which are left
+// as text.
+func (p *PostIndex) replaceWikiLinks(doc ast.Node) error {
+	var unresolved, ambiguous []string
+	walkWikiText(doc, func(node *ast.Text) {
+		var nodes []ast.Node
+		text := node.Literal
+		last := 0
+		for _, m := range wikiLinkRegexp.FindAllSubmatchIndex(text, -1) {
+			target := string(text[m[2]:m[3]])
+			post, err := p.resolveWikiTarget(target)
+			if err != nil {
+				ambiguous = append(ambiguous, err.Error())
+				continue
+			}
+			if post == nil {
+				unresolved = append(unresolved, "[["+target+"]]")
+				continue
+			}
+			label := post.Title
+			if m[4] >= 0 && strings.TrimSpace(string(text[m[4]:m[5]])) != "" {
+				label = strings.TrimSpace(string(text[m[4]:m[5]]))
+			}
+			if m[0] > last {
+				nodes = append(nodes, &ast.Text{
+					Leaf: ast.Leaf{Literal: text[last:m[0]]}})
+			}
+			link := &ast.Link{Destination: []byte(post.RelativeURL())}
+			ast.AppendChild(link, &ast.Text{Leaf: ast.Leaf{Literal: []byte(label)}})
+			nodes = append(nodes, link)
+			last = m[1]
+		}
+		if len(nodes) == 0 {
+			return
+		}
+		if last < len(text) {
+			nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: text[last:]}})
+		}
+		replaceNode(node, nodes)
+	})
+	var problems []string
+	if len(unresolved) > 0 {
+		problems = append(problems, fmt.Sprintf("unresolved wiki link(s): %s",
+			strings.Join(unresolved, ", ")))
+	}
+	problems = append(problems, ambiguous...)
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Replaces node, within its parent, with nodes.
+func replaceNode(node ast.Node, nodes []ast.Node) {
+	parent := node.GetParent()
+	var children []ast.Node
+	for _, child := range parent.GetChildren() {
+		if child != node {
+			children = append(children, child)
+			continue
+		}
+		for _, n := range nodes {
+			n.SetParent(parent)
+			children = append(children, n)
+		}
+	}
+	parent.SetChildren(children)
+}
+
+// Finds the posts that each post is linked to from, newest first,
+// including by links in the text it includes or its shortcodes.
+func findBacklinks(p *PostIndex) (map[*Post][]*Post, error) {
+	backlinks := make(map[*Post][]*Post)
+	for _, post := range p.Posts {
+		doc, err := post.parseExpanded()
+		if err != nil {
+			return nil, err
+		}
+		links := findWikiLinks(doc)
+		seen := make(map[*Post]bool)
+		for _, link := range links {
+			// Ambiguous links are reported when the post is rendered.
+			target, _ := p.resolveWikiTarget(link.target)
+			if target == nil || target == post || seen[target] {
+				continue
+			}
+			seen[target] = true
+			backlinks[target] = append(backlinks[target], post)
+		}
+	}
+	return backlinks, nil
+}
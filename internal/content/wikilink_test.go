@@ -0,0 +1,84 @@
+package content
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWikiLinks(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"posts/2020-01-01-intro/index.md": "% Intro\n\nNo links.\n",
+		"posts/2020-01-02-next/index.md": "% Next\n\n" +
+			"After [[intro]], see [[rust/traits|traits]] and `[[intro]]`.\n",
+		"posts/rust/2020-01-03-traits/index.md": "% Traits\n\n" +
+			"Back to [[ intro | the start ]] and [[next]] and [[intro]].\n",
+		"posts/2020-01-04-broken/index.md":     "% Broken\n\nSee [[missing]].\n",
+		"posts/go/2020-01-05-setup/index.md":   "% Go setup\n",
+		"posts/rust/2020-01-05-setup/index.md": "% Rust setup\n",
+		"posts/2020-01-06-vague/index.md":      "% Vague\n\nSee [[setup]] and [[go/setup]].\n",
+	})
+	pi, err := NewPostIndex(root, nil)
+	if err != nil {
+		t.Fatalf("NewPostIndex failed: %v", err)
+	}
+
+	t.Run("HTML", func(t *testing.T) {
+		html, err := pi.Get("", "next").HTML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `<p>After <a href="/posts/intro/">Intro</a>, see ` +
+			`<a href="/posts/rust/traits/">traits</a> and <code>[[intro]]</code>.</p>`
+		if !strings.Contains(string(html), expected) {
+			t.Errorf("HTML() returned\n%s\nnot containing\n%s", html, expected)
+		}
+
+		html, err = pi.Get("rust", "traits").HTML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = `Back to <a href="/posts/intro/">the start</a> and ` +
+			`<a href="/posts/next/">Next</a> and <a href="/posts/intro/">Intro</a>.`
+		if !strings.Contains(string(html), expected) {
+			t.Errorf("HTML() returned\n%s\nnot containing\n%s", html, expected)
+		}
+	})
+
+	t.Run("Unresolved", func(t *testing.T) {
+		_, err := pi.Get("", "broken").HTML()
+		if err == nil || !strings.Contains(err.Error(), "[[missing]]") {
+			t.Errorf("HTML() returned error %v", err)
+		}
+	})
+
+	t.Run("Ambiguous", func(t *testing.T) {
+		_, err := pi.Get("", "vague").HTML()
+		expected := "wiki link [[setup]] is ambiguous: in series go, rust"
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("HTML() returned error %v, not containing %s", err, expected)
+		}
+	})
+
+	t.Run("GetBacklinks", func(t *testing.T) {
+		for name, expected := range map[string][]string{
+			"intro":  {"traits", "next"},
+			"next":   {"traits"},
+			"traits": {"next"},
+			"broken": nil,
+		} {
+			post, err := pi.resolveWikiTarget(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actual []string
+			for _, p := range pi.GetBacklinks(post) {
+				actual = append(actual, p.Name)
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("GetBacklinks(%s) = %q, not %q", name, actual, expected)
+			}
+		}
+	})
+}
@@ -4,10 +4,12 @@ import (
 	"io"
 	"net/url"
 	"path"
+	"regexp"
 	"strings"
 
 	"github.com/gorilla/feeds"
 	"github.com/hblanks/speakwrite/internal/content"
+	"github.com/hblanks/speakwrite/internal/mathml"
 )
 
 const maxItems = 50
@@ -33,15 +35,39 @@ func toDescription(m *content.PostMetadata) string {
 	return m.Deck + " " + tags
 }
 
+// Inline math, as pandoc finds it: no space just inside the dollar
+// signs. A digit may not follow the closing one, so "$5 to $10" isn't math.
+var mathRegexp = regexp.MustCompile(`\$([^\s$](?:[^$]*[^\s$])?)\$`)
+
+// Replaces inline LaTeX math in s with Unicode text, e.g. "$x^2$" with
+// "x²", since feed readers can't render either MathML or MathJax. Leaves
+// math it can't convert as it is.
+func mathText(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range mathRegexp.FindAllStringSubmatchIndex(s, -1) {
+		if m[1] < len(s) && '0' <= s[m[1]] && s[m[1]] <= '9' {
+			continue
+		}
+		text, err := mathml.ToText(s[m[2]:m[3]])
+		if err != nil {
+			continue
+		}
+		b.WriteString(s[last:m[0]] + text)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
 func join(u url.URL, relPath string) string {
 	u.Path = path.Join(u.Path, relPath) // Safe b/c u is not a pointer.
 	return u.String()
 }
 
 // Takes the base (unnamed) series and an ordered slice of posts.
-// Constructs an RSS feed and writes the most recent posts out
-// to it.
-func WriteRSS(publicURL *url.URL, md *content.SeriesMetadata, posts []*content.Post, w io.Writer) error {
+// Constructs a feed of the most recent posts.
+func newFeed(publicURL *url.URL, md *content.SeriesMetadata, posts []*content.Post) *feeds.Feed {
 	feed := &feeds.Feed{
 		Title:       md.Title,
 		Link:        &feeds.Link{Href: publicURL.String()},
@@ -58,14 +84,32 @@ func WriteRSS(publicURL *url.URL, md *content.SeriesMetadata, posts []*content.P
 		if i > maxItems {
 			break
 		}
+		link := join(*publicURL, p.RelativeURL())
 		item := &feeds.Item{
-			Title:       toTitle(p.Series, p.Title),
-			Link:        &feeds.Link{Href: join(*publicURL, p.RelativeURL())},
-			Description: toDescription(&p.Metadata),
+			Id:          link,
+			Title:       mathText(toTitle(p.Series, p.Title)),
+			Link:        &feeds.Link{Href: link},
+			Description: mathText(toDescription(&p.Metadata)),
 			Created:     p.Date,
+			Updated:     p.LastModified(),
+		}
+		if item.Updated.After(feed.Updated) {
+			feed.Updated = item.Updated
 		}
 		feed.Items = append(feed.Items, item)
 	}
+	return feed
+}
+
+// Takes the base (unnamed) series and an ordered slice of posts.
+// Constructs an RSS feed and writes the most recent posts out
+// to it.
+func WriteRSS(publicURL *url.URL, md *content.SeriesMetadata, posts []*content.Post, w io.Writer) error {
+	return newFeed(publicURL, md, posts).WriteRss(w)
+}
 
-	return feed.WriteRss(w)
+// Like WriteRSS, but writes an Atom feed, which unlike RSS can say
+// when each post was last updated.
+func WriteAtom(publicURL *url.URL, md *content.SeriesMetadata, posts []*content.Post, w io.Writer) error {
+	return newFeed(publicURL, md, posts).WriteAtom(w)
 }
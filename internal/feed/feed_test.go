@@ -3,6 +3,7 @@ package feed
 import (
 	"bytes"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -54,3 +55,76 @@ func TestWriteRSS(t *testing.T) {
 		t.Errorf("WriteRSS() wrote no bytes")
 	}
 }
+
+func TestWriteAtom(t *testing.T) {
+	series := &content.Series{
+		SeriesMetadata: content.SeriesMetadata{Title: "Here's a series"},
+	}
+	posts := []*content.Post{
+		&content.Post{
+			Name:    "revised-post",
+			Title:   "Revised post",
+			Date:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			Updated: time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC),
+			Series:  series,
+		},
+	}
+
+	u, _ := url.Parse("https://example.com/")
+
+	buf := &bytes.Buffer{}
+	err := WriteAtom(u, &series.SeriesMetadata, posts, buf)
+	if err != nil {
+		t.Fatalf("WriteAtom() returned unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "<updated>2020-03-01T12:00:00Z</updated>") {
+		t.Errorf("WriteAtom() did not write <updated>: %s", buf.String())
+	}
+}
+
+func TestWriteSitemap(t *testing.T) {
+	series := &content.Series{}
+	posts := []*content.Post{
+		&content.Post{
+			Name:    "revised-post",
+			Date:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			Updated: time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC),
+			Series:  series,
+		},
+		&content.Post{
+			Name:   "first-post",
+			Date:   time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+			Series: series,
+		},
+	}
+
+	u, _ := url.Parse("https://example.com/")
+
+	buf := &bytes.Buffer{}
+	if err := WriteSitemap(u, posts, buf); err != nil {
+		t.Fatalf("WriteSitemap() returned unexpected error: %v", err)
+	}
+	for _, s := range []string{
+		"<loc>https://example.com/</loc>\n    <lastmod>2020-03-01</lastmod>",
+		"<loc>https://example.com/posts/revised-post/</loc>\n    <lastmod>2020-03-01</lastmod>",
+		"<loc>https://example.com/posts/first-post/</loc>\n    <lastmod>2019-01-01</lastmod>",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("WriteSitemap() output missing %q: %s", s, buf.String())
+		}
+	}
+}
+
+func TestMathText(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"Proving $a^2 + b^2 = c^2$", "Proving a² + b² = c²"},
+		{"On $\\frac{1}{2}$ and $\\alpha$", "On 1/2 and α"},
+		{"From $5 to $10", "From $5 to $10"},
+		{"Costs $ 5 $", "Costs $ 5 $"},
+		{"An $\\unknown$ command", "An $\\unknown$ command"},
+	} {
+		if got := mathText(tc.in); got != tc.want {
+			t.Errorf("mathText(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
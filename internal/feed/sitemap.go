@@ -0,0 +1,66 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/hblanks/speakwrite/internal/content"
+)
+
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name      `xml:"urlset"`
+	XMLNS   string        `xml:"xmlns,attr"`
+	URLs    []*sitemapURL `xml:"url"`
+}
+
+func lastMod(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(content.IsoDateFormat)
+}
+
+// Writes a sitemap listing the site root and every post, with each
+// post's <lastmod> taken from when it was last updated.
+func WriteSitemap(publicURL *url.URL, posts []*content.Post, w io.Writer) error {
+	urlSet := &sitemapURLSet{
+		XMLNS: sitemapNS,
+		URLs:  make([]*sitemapURL, 0, len(posts)+1),
+	}
+
+	root := &sitemapURL{Loc: join(*publicURL, "/")}
+	urlSet.URLs = append(urlSet.URLs, root)
+
+	var latest time.Time
+	for _, p := range posts {
+		modified := p.LastModified()
+		if modified.After(latest) {
+			latest = modified
+		}
+		urlSet.URLs = append(urlSet.URLs, &sitemapURL{
+			Loc:     join(*publicURL, p.RelativeURL()) + "/",
+			LastMod: lastMod(modified),
+		})
+	}
+	root.LastMod = lastMod(latest)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(urlSet); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
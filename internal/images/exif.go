@@ -0,0 +1,119 @@
+package images
+
+//
+// EXIF orientation, which says how a JPEG (often a phone photo) must be
+// rotated or flipped to display upright.
+//
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// The EXIF orientation tag.
+const orientationTag = 0x0112
+
+// Returns the EXIF orientation of a JPEG, from 1 (upright) to 8, or 1
+// if it has none.
+func exifOrientation(data []byte) int {
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+		return 1
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		if marker == 0xDA { // Start of scan: no more metadata.
+			break
+		}
+		// The length counts its own two bytes, so it's at least 2.
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end < i+4 || end > len(data) {
+			break
+		}
+		segment := data[i+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i = end
+	}
+	return 1
+}
+
+// Returns the orientation in the first IFD of TIFF data, as in EXIF, or
+// 1 if it has none.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < n; i++ {
+		entry := ifd + 2 + 12*i
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == orientationTag {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 1
+			}
+			return o
+		}
+	}
+	return 1
+}
+
+// Returns whether an orientation swaps width and height.
+func transposes(orientation int) bool {
+	return orientation >= 5
+}
+
+// Returns img rotated and flipped upright, according to its EXIF
+// orientation.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if transposes(orientation) {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Flipped horizontally.
+				sx, sy = w-1-x, y
+			case 3: // Rotated 180°.
+				sx, sy = w-1-x, h-1-y
+			case 4: // Flipped vertically.
+				sx, sy = x, h-1-y
+			case 5: // Transposed.
+				sx, sy = y, x
+			case 6: // Needs rotating 90° clockwise.
+				sx, sy = y, h-1-x
+			case 7: // Transversed.
+				sx, sy = w-1-y, h-1-x
+			case 8: // Needs rotating 90° counterclockwise.
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,257 @@
+package images
+
+//
+// Generates resized variants of post images, and rewrites <img> tags to
+// offer them to browsers via srcset.
+//
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+const jpegQuality = 85
+
+// Options for a Processor.
+type Options struct {
+	// Widths, in pixels, at which to generate variants. Variants are
+	// only generated at widths narrower than the original image. If
+	// empty, no variants are generated and <img> tags aren't rewritten.
+	Widths []int
+	// The value of the sizes attribute for rewritten <img> tags.
+	Sizes string
+	// Where to keep generated variants.
+	CacheDir string
+}
+
+// Returns the default cache directory for generated variants.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "speakwrite", "images")
+}
+
+// Parses a comma-separated list of widths, e.g. "480,960,1600".
+func ParseWidths(s string) ([]int, error) {
+	var widths []int
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		w, err := strconv.Atoi(field)
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("Invalid image width %q", field)
+		}
+		widths = append(widths, w)
+	}
+	sort.Ints(widths)
+	return widths, nil
+}
+
+// Returns whether a file name has an extension we can resize.
+func IsResizable(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// Pattern for a variant name: ${BASE}.${WIDTH}w.${EXT}
+var variantRegexp = regexp.MustCompile(`^(.*)\.(\d+)w(\.[^.]+)$`)
+
+// Returns the name of a variant of the image at name, e.g.
+// "photos/cat.480w.jpg" for "photos/cat.jpg".
+func VariantName(name string, width int) string {
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s.%dw%s", strings.TrimSuffix(name, ext), width, ext)
+}
+
+// The reverse of VariantName.
+func ParseVariantName(name string) (string, int, bool) {
+	m := variantRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return "", 0, false
+	}
+	width, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return m[1] + m[3], width, true
+}
+
+// The dimensions and content hash of a source image.
+type Info struct {
+	Width, Height int // As displayed, i.e. after any EXIF orientation.
+	Hash          string
+
+	orientation int // EXIF orientation, or 1 for none.
+}
+
+type infoKey struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// A Processor generates and caches image variants.
+type Processor struct {
+	opts Options
+
+	mu    sync.Mutex
+	infos map[infoKey]*Info
+}
+
+func NewProcessor(opts Options) *Processor {
+	if opts.Sizes == "" {
+		opts.Sizes = "100vw"
+	}
+	if opts.CacheDir == "" {
+		opts.CacheDir = DefaultCacheDir()
+	}
+	return &Processor{
+		opts:  opts,
+		infos: make(map[infoKey]*Info),
+	}
+}
+
+// Returns whether any variants will be generated.
+func (p *Processor) Enabled() bool {
+	return p != nil && len(p.opts.Widths) > 0
+}
+
+// Returns the dimensions and hash of the image at path, reading the file
+// only if it's changed since last asked.
+func (p *Processor) Info(path string) (*Info, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	key := infoKey{path, st.Size(), st.ModTime()}
+
+	p.mu.Lock()
+	info := p.infos[key]
+	p.mu.Unlock()
+	if info != nil {
+		return info, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	info = &Info{
+		Width:       config.Width,
+		Height:      config.Height,
+		Hash:        hex.EncodeToString(sum[:]),
+		orientation: exifOrientation(data),
+	}
+	if transposes(info.orientation) {
+		info.Width, info.Height = info.Height, info.Width
+	}
+
+	p.mu.Lock()
+	p.infos[key] = info
+	p.mu.Unlock()
+	return info, nil
+}
+
+// Returns the widths at which variants of an image are generated.
+func (p *Processor) Widths(info *Info) []int {
+	var widths []int
+	for _, w := range p.opts.Widths {
+		if w < info.Width {
+			widths = append(widths, w)
+		}
+	}
+	return widths
+}
+
+// Returns the path to a variant of the image at path, generating it if
+// it isn't already cached. Variants are cached by the hash of their
+// source, so edited images (including ones only re-oriented, since
+// orientation is in the file) get new variants.
+func (p *Processor) Variant(path string, width int) (string, error) {
+	info, err := p.Info(path)
+	if err != nil {
+		return "", err
+	}
+	valid := false
+	for _, w := range p.Widths(info) {
+		valid = valid || w == width
+	}
+	if !valid {
+		return "", fmt.Errorf("%s: no variant at width %d", path, width)
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	name := fmt.Sprintf("%s-%d%s", info.Hash, width, ext)
+	cachePath := filepath.Join(p.opts.CacheDir, info.Hash[:2], name)
+	if _, err := os.Stat(cachePath); err == nil {
+		return cachePath, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	src, _, err := image.Decode(f)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	src = orient(src, info.orientation)
+
+	height := (info.Height*width + info.Width/2) / info.Width
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(cachePath), "")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed.
+	if ext == ".png" {
+		err = png.Encode(tmp, dst)
+	} else {
+		err = jpeg.Encode(tmp, dst, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return "", err
+	}
+	return cachePath, nil
+}
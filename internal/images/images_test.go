@@ -0,0 +1,189 @@
+package images
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeJPEG(t *testing.T, path string, width, height int) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("writeJPEG error: %v", err)
+	}
+	defer f.Close()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if err := jpeg.Encode(f, img, nil); err != nil {
+		t.Fatalf("writeJPEG error: %v", err)
+	}
+}
+
+func TestVariantName(t *testing.T) {
+	name := VariantName("photos/cat.jpg", 480)
+	if name != "photos/cat.480w.jpg" {
+		t.Errorf("VariantName() = %s", name)
+	}
+	orig, width, ok := ParseVariantName(name)
+	if !ok || orig != "photos/cat.jpg" || width != 480 {
+		t.Errorf("ParseVariantName(%s) = %s, %d, %v", name, orig, width, ok)
+	}
+	if _, _, ok := ParseVariantName("cat.jpg"); ok {
+		t.Errorf("ParseVariantName() parsed a non-variant")
+	}
+}
+
+func TestProcessor(t *testing.T) {
+	dir := t.TempDir()
+	writeJPEG(t, filepath.Join(dir, "wide.jpg"), 1000, 500)
+	writeJPEG(t, filepath.Join(dir, "narrow.jpg"), 300, 300)
+
+	p := NewProcessor(Options{
+		Widths:   []int{480, 960, 1600},
+		CacheDir: filepath.Join(dir, "cache"),
+	})
+
+	t.Run("Variant", func(t *testing.T) {
+		variant, err := p.Variant(filepath.Join(dir, "wide.jpg"), 480)
+		if err != nil {
+			t.Fatalf("Variant() returned unexpected error: %v", err)
+		}
+		f, err := os.Open(variant)
+		if err != nil {
+			t.Fatalf("Variant() did not write %s: %v", variant, err)
+		}
+		defer f.Close()
+		config, _, err := image.DecodeConfig(f)
+		if err != nil {
+			t.Fatalf("Variant() wrote an invalid image: %v", err)
+		}
+		if config.Width != 480 || config.Height != 240 {
+			t.Errorf("Variant() is %dx%d, not 480x240", config.Width, config.Height)
+		}
+
+		if _, err := p.Variant(filepath.Join(dir, "wide.jpg"), 1600); err == nil {
+			t.Errorf("Variant() upscaled an image")
+		}
+	})
+
+	t.Run("RewriteHTML", func(t *testing.T) {
+		src := `<p><img src="wide.jpg" alt="Wide" />` +
+			`<img src="narrow.jpg" alt="Narrow">` +
+			`<img src="https://example.com/remote.jpg" alt="Remote"></p>`
+		expected := `<p><img src="wide.jpg" alt="Wide" ` +
+			`srcset="wide.480w.jpg 480w, wide.960w.jpg 960w, wide.jpg 1000w" ` +
+			`sizes="100vw" width="1000" height="500" loading="lazy" />` +
+			`<img src="narrow.jpg" alt="Narrow" width="300" height="300" loading="lazy">` +
+			`<img src="https://example.com/remote.jpg" alt="Remote"></p>`
+		actual := string(p.RewriteHTML([]byte(src), dir))
+		if actual != expected {
+			t.Errorf("RewriteHTML():\n\texpected %s\n\tactual   %s", expected, actual)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		src := `<img src="wide.jpg">`
+		actual := string(NewProcessor(Options{}).RewriteHTML([]byte(src), dir))
+		if actual != src {
+			t.Errorf("RewriteHTML() rewrote with no widths: %s", actual)
+		}
+	})
+}
+
+// Writes a JPEG whose left half is red and right half blue, with an EXIF
+// orientation.
+func writeOrientedJPEG(t *testing.T, path string, width, height, orientation int) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= width/2 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("writeOrientedJPEG error: %v", err)
+	}
+
+	// A big-endian TIFF header and one IFD entry: orientation, a SHORT.
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1,
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, byte(orientation), 0, 0,
+		0, 0, 0, 0}
+	exif := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	data := buf.Bytes()
+	data = append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("writeOrientedJPEG error: %v", err)
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "phone.jpg")
+	writeOrientedJPEG(t, path, 1000, 500, 6) // Needs rotating clockwise.
+
+	p := NewProcessor(Options{
+		Widths:   []int{480},
+		CacheDir: filepath.Join(dir, "cache"),
+	})
+	info, err := p.Info(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Width != 500 || info.Height != 1000 {
+		t.Errorf("Info() is %dx%d, not 500x1000", info.Width, info.Height)
+	}
+
+	variant, err := p.Variant(path, 480)
+	if err != nil {
+		t.Fatalf("Variant() returned unexpected error: %v", err)
+	}
+	f, err := os.Open(variant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 480 || b.Dy() != 960 {
+		t.Errorf("Variant() is %dx%d, not 480x960", b.Dx(), b.Dy())
+	}
+	// The left (red) half is now on top.
+	for _, tc := range []struct {
+		y   int
+		red bool
+	}{{100, true}, {860, false}} {
+		r, _, b, _ := img.At(240, tc.y).RGBA()
+		if (r > b) != tc.red {
+			t.Errorf("Variant() at y=%d has r=%d b=%d", tc.y, r, b)
+		}
+	}
+}
+
+func TestExifOrientation(t *testing.T) {
+	if o := exifOrientation([]byte{0xFF, 0xD8, 0xFF, 0xDA}); o != 1 {
+		t.Errorf("exifOrientation() without EXIF = %d", o)
+	}
+	if o := exifOrientation([]byte("not a jpeg")); o != 1 {
+		t.Errorf("exifOrientation() of a non-JPEG = %d", o)
+	}
+	for _, data := range [][]byte{
+		{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x00},
+		{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01, 0xFF, 0xDA},
+		{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x10, 'E', 'x'},
+	} {
+		if o := exifOrientation(data); o != 1 {
+			t.Errorf("exifOrientation(% x) = %d", data, o)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	nethtml "golang.org/x/net/html"
+)
+
+// Returns the path of the file an <img> src refers to within dir, if it's
+// a relative URL that stays within dir.
+func localPath(dir, src string) (string, bool) {
+	u, err := url.Parse(src)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" ||
+		strings.HasPrefix(u.Path, "/") {
+		return "", false
+	}
+	clean := path.Clean(u.Path)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), true
+}
+
+func getAttr(attrs []nethtml.Attribute, key string) (string, bool) {
+	for _, a := range attrs {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// Returns the attributes to add to an <img> tag with the given src in
+// dir, or nil if it isn't a local, resizable image.
+func (p *Processor) imgAttrs(dir string, attrs []nethtml.Attribute) []nethtml.Attribute {
+	src, _ := getAttr(attrs, "src")
+	if _, ok := getAttr(attrs, "srcset"); ok || !IsResizable(src) {
+		return nil
+	}
+	fpath, ok := localPath(dir, src)
+	if !ok {
+		return nil
+	}
+	info, err := p.Info(fpath)
+	if err != nil {
+		log.Printf("images: %v", err)
+		return nil
+	}
+
+	var added []nethtml.Attribute
+	if widths := p.Widths(info); len(widths) > 0 {
+		candidates := make([]string, 0, len(widths)+1)
+		for _, w := range widths {
+			candidates = append(candidates,
+				fmt.Sprintf("%s %dw", VariantName(src, w), w))
+		}
+		candidates = append(candidates, fmt.Sprintf("%s %dw", src, info.Width))
+		added = append(added,
+			nethtml.Attribute{Key: "srcset", Val: strings.Join(candidates, ", ")},
+			nethtml.Attribute{Key: "sizes", Val: p.opts.Sizes})
+	}
+	_, hasWidth := getAttr(attrs, "width")
+	_, hasHeight := getAttr(attrs, "height")
+	if !hasWidth && !hasHeight {
+		added = append(added,
+			nethtml.Attribute{Key: "width", Val: strconv.Itoa(info.Width)},
+			nethtml.Attribute{Key: "height", Val: strconv.Itoa(info.Height)})
+	}
+	if _, ok := getAttr(attrs, "loading"); !ok {
+		added = append(added, nethtml.Attribute{Key: "loading", Val: "lazy"})
+	}
+	return added
+}
+
+// Rewrites <img> tags referring to images within dir so that they offer
+// resized variants via srcset and sizes, give their dimensions, and load
+// lazily. Everything else is passed through untouched.
+func (p *Processor) RewriteHTML(src []byte, dir string) []byte {
+	if !p.Enabled() {
+		return src
+	}
+
+	var out bytes.Buffer
+	z := nethtml.NewTokenizer(bytes.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		// Token() may overwrite what Raw() returns, so copy it.
+		raw := append([]byte(nil), z.Raw()...)
+		if tt != nethtml.StartTagToken && tt != nethtml.SelfClosingTagToken {
+			out.Write(raw)
+			continue
+		}
+		token := z.Token()
+		if token.Data != "img" {
+			out.Write(raw)
+			continue
+		}
+		added := p.imgAttrs(dir, token.Attr)
+		if added == nil {
+			out.Write(raw)
+			continue
+		}
+
+		out.WriteString("<img")
+		for _, a := range append(token.Attr, added...) {
+			fmt.Fprintf(&out, ` %s="%s"`, a.Key, html.EscapeString(a.Val))
+		}
+		if bytes.HasSuffix(raw, []byte("/>")) {
+			out.WriteString(" />")
+		} else {
+			out.WriteString(">")
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,198 @@
+package linkcheck
+
+//
+// Finds links within a site that point to pages or fragments that don't
+// exist.
+//
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// A BrokenLink is a link within the site that points nowhere.
+type BrokenLink struct {
+	Page   string // URL of the page containing the link
+	Link   string // the link as written
+	Reason string
+}
+
+func (b BrokenLink) String() string {
+	return fmt.Sprintf("%s: %s: %s", b.Page, b.Link, b.Reason)
+}
+
+// A page as parsed for links.
+type page struct {
+	url   *url.URL
+	ids   map[string]bool
+	links []string
+}
+
+// Attributes holding URLs, by element.
+var linkAttrs = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"img":    {"src", "srcset"},
+	"source": {"src", "srcset"},
+	"script": {"src"},
+	"iframe": {"src"},
+	"audio":  {"src"},
+	"video":  {"src", "poster"},
+	"track":  {"src"},
+	"embed":  {"src"},
+}
+
+// Returns the URLs listed in a srcset attribute.
+func splitSrcset(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// Parses an HTML page, collecting the ids of its elements and the URLs
+// it links to.
+func parsePage(u *url.URL, r *httptest.ResponseRecorder) (*page, error) {
+	doc, err := html.Parse(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	p := &page{url: u, ids: make(map[string]bool)}
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			attrs := linkAttrs[n.Data]
+			for _, a := range n.Attr {
+				if a.Namespace != "" {
+					continue
+				}
+				switch {
+				case a.Key == "id", a.Key == "name" && n.Data == "a":
+					p.ids[a.Val] = true
+				case a.Key == "srcset" && contains(attrs, a.Key):
+					p.links = append(p.links, splitSrcset(a.Val)...)
+				case contains(attrs, a.Key):
+					p.links = append(p.links, strings.TrimSpace(a.Val))
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(doc)
+	return p, nil
+}
+
+func contains(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the key under which a URL's page is known, mapping directory
+// URLs and their index.html to the same page.
+func pageKey(u *url.URL) string {
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+	if strings.HasSuffix(p, "/index.html") {
+		p = strings.TrimSuffix(p, "index.html")
+	}
+	return p
+}
+
+// Checks every link in every HTML page served by h at urls. Links are
+// resolved against the page they're on; only those within publicURL are
+// checked, and a link is broken if it maps to none of urls or names a
+// fragment with no matching id on its page.
+func Check(h http.Handler, publicURL *url.URL, urls []string) ([]BrokenLink, error) {
+	pages := make(map[string]*page, len(urls))
+	var htmlPages []*page
+
+	for _, rawURL := range urls {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		p := &page{url: u}
+		pages[pageKey(u)] = p
+
+		req := httptest.NewRequest("GET", rawURL, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			return nil, fmt.Errorf("URL %s returned %d, not 200", rawURL, w.Code)
+		}
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		if mediaType != "text/html" {
+			continue
+		}
+		if p, err = parsePage(u, w); err != nil {
+			return nil, fmt.Errorf("URL %s: %w", rawURL, err)
+		}
+		pages[pageKey(u)] = p
+		htmlPages = append(htmlPages, p)
+	}
+
+	sitePath := publicURL.Path
+	if !strings.HasSuffix(sitePath, "/") {
+		sitePath += "/"
+	}
+
+	var broken []BrokenLink
+	for _, p := range htmlPages {
+		for _, link := range p.links {
+			ref, err := url.Parse(link)
+			if err != nil {
+				broken = append(broken, BrokenLink{p.url.String(), link, "unparseable"})
+				continue
+			}
+			target := p.url.ResolveReference(ref)
+			if target.Scheme != publicURL.Scheme || target.Host != publicURL.Host ||
+				!strings.HasPrefix(target.Path+"/", sitePath) {
+				continue // Not within the site.
+			}
+
+			key := pageKey(target)
+			targetPage := pages[key]
+			if targetPage == nil && !strings.HasSuffix(key, "/") {
+				// Servers redirect directories without a trailing slash.
+				targetPage = pages[key+"/"]
+			}
+			switch {
+			case targetPage == nil:
+				broken = append(broken, BrokenLink{p.url.String(), link,
+					fmt.Sprintf("no page at %s", path.Clean(target.Path))})
+			case target.Fragment == "":
+			case targetPage.ids == nil:
+				broken = append(broken, BrokenLink{p.url.String(), link,
+					"fragment on a page that isn't HTML"})
+			case !targetPage.ids[target.Fragment]:
+				broken = append(broken, BrokenLink{p.url.String(), link,
+					fmt.Sprintf("no id %q on %s", target.Fragment, key)})
+			}
+		}
+	}
+
+	sort.SliceStable(broken, func(i, j int) bool {
+		return broken[i].Page < broken[j].Page
+	})
+	return broken, nil
+}
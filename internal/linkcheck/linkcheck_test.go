@@ -0,0 +1,70 @@
+package linkcheck
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+var testPages = map[string]string{
+	"/": `<a href="/posts/a/">A</a>
+		<a href="/posts/b/">B</a>
+		<a href="posts/a/#section">section</a>
+		<a href="https://elsewhere.example.com/nope/">external</a>
+		<a href="mailto:someone@example.com">mail</a>`,
+	"/posts/a/": `<h2 id="section">Section</h2>
+		<img src="cat.png" srcset="cat.480w.png 480w, dog.480w.png 480w">
+		<a href="#missing">missing</a>
+		<a href="/posts/a">no slash</a>`,
+	"/posts/a/cat.png":      "",
+	"/posts/a/cat.480w.png": "",
+}
+
+type testHandler struct{}
+
+func (testHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, ok := testPages[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.URL.Path[len(r.URL.Path)-1] == '/' {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "image/png")
+	}
+	w.Write([]byte(body))
+}
+
+func TestCheck(t *testing.T) {
+	publicURL, _ := url.Parse("https://example.com/")
+	urls := make([]string, 0, len(testPages))
+	for p := range testPages {
+		urls = append(urls, "https://example.com"+p)
+	}
+
+	broken, err := Check(testHandler{}, publicURL, urls)
+	if err != nil {
+		t.Fatalf("Check() returned unexpected error: %v", err)
+	}
+
+	expected := []BrokenLink{
+		{"https://example.com/", "/posts/b/", "no page at /posts/b"},
+		{"https://example.com/posts/a/", "dog.480w.png", "no page at /posts/a/dog.480w.png"},
+		{"https://example.com/posts/a/", "#missing", `no id "missing" on /posts/a/`},
+	}
+	if len(broken) != len(expected) {
+		t.Fatalf("Check() found %d broken links, not %d: %v",
+			len(broken), len(expected), broken)
+	}
+	for _, e := range expected {
+		found := false
+		for _, b := range broken {
+			found = found || reflect.DeepEqual(b, e)
+		}
+		if !found {
+			t.Errorf("Check() did not report %v; got %v", e, broken)
+		}
+	}
+}
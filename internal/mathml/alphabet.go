@@ -0,0 +1,40 @@
+package mathml
+
+// A Unicode mathematical alphabet.
+type alphabet struct {
+	upper, lower, digit rune // First letters, or 0 for none.
+	exceptions          map[rune]rune
+}
+
+// Alphabets, by the commands that select them.
+var alphabets = map[string]alphabet{
+	"mathbf": {0x1D400, 0x1D41A, 0x1D7CE, nil},
+	"mathbb": {0x1D538, 0x1D552, 0x1D7D8, map[rune]rune{
+		'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+	}},
+	"mathcal": {0x1D49C, 0x1D4B6, 0, map[rune]rune{
+		'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ',
+		'R': 'ℛ', 'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ',
+	}},
+	"mathfrak": {0x1D504, 0x1D51E, 0, map[rune]rune{
+		'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ',
+	}},
+	"mathsf": {0x1D5A0, 0x1D5BA, 0x1D7E2, nil},
+	"mathtt": {0x1D670, 0x1D68A, 0x1D7F6, nil},
+}
+
+// Returns r in the alphabet, or r itself if the alphabet lacks it.
+func (a alphabet) convert(r rune) rune {
+	if c, ok := a.exceptions[r]; ok {
+		return c
+	}
+	switch {
+	case 'A' <= r && r <= 'Z' && a.upper != 0:
+		return a.upper + r - 'A'
+	case 'a' <= r && r <= 'z' && a.lower != 0:
+		return a.lower + r - 'a'
+	case '0' <= r && r <= '9' && a.digit != 0:
+		return a.digit + r - '0'
+	}
+	return r
+}
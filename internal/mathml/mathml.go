@@ -0,0 +1,312 @@
+package mathml
+
+//
+// Converts a subset of LaTeX math to MathML, which browsers render
+// without JavaScript, or to plain Unicode text, for places like feed
+// titles that can't have markup.
+//
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// Part of a formula.
+type node interface {
+	writeMathML(b *strings.Builder)
+	writeText(b *strings.Builder)
+}
+
+// A token element: <mi>, <mn>, <mo> or <mtext>.
+type token struct {
+	tag   string
+	text  string
+	attrs string // e.g. ` mathvariant="normal"`
+}
+
+// A sequence of nodes.
+type row []node
+
+// A base with a subscript and/or superscript, either of which may be nil.
+type script struct {
+	base, sub, sup node
+	limits         bool // Whether the scripts go below and above.
+}
+
+type fraction struct {
+	num, den node
+	binom    bool
+}
+
+type root struct {
+	index    node // nil for a square root
+	radicand node
+}
+
+// A row between delimiters that stretch to fit it.
+type fenced struct {
+	open, close string // "" for none
+	body        row
+}
+
+type accent struct {
+	base            node
+	over, combining string
+	under           bool
+}
+
+type space struct {
+	width string
+}
+
+func (t token) writeMathML(b *strings.Builder) {
+	b.WriteString("<" + t.tag + t.attrs + ">" + html.EscapeString(t.text) +
+		"</" + t.tag + ">")
+}
+
+func (t token) writeText(b *strings.Builder) {
+	// Space out binary operators, but not unary ones, which come first
+	// or after another operator.
+	s := b.String()
+	unary := s == "" || strings.HasSuffix(s, " ") || strings.HasSuffix(s, "(")
+	if t.tag == "mo" && spacedOperators[t.text] && !unary {
+		b.WriteString(" " + t.text + " ")
+		return
+	}
+	b.WriteString(t.text)
+}
+
+func (r row) writeMathML(b *strings.Builder) {
+	if len(r) == 1 {
+		r[0].writeMathML(b)
+		return
+	}
+	b.WriteString("<mrow>")
+	for _, n := range r {
+		n.writeMathML(b)
+	}
+	b.WriteString("</mrow>")
+}
+
+func (r row) writeText(b *strings.Builder) {
+	for _, n := range r {
+		n.writeText(b)
+	}
+}
+
+func (s script) writeMathML(b *strings.Builder) {
+	tag := "msubsup"
+	switch {
+	case s.limits && s.sup == nil:
+		tag = "munder"
+	case s.limits && s.sub == nil:
+		tag = "mover"
+	case s.limits:
+		tag = "munderover"
+	case s.sup == nil:
+		tag = "msub"
+	case s.sub == nil:
+		tag = "msup"
+	}
+	b.WriteString("<" + tag + ">")
+	s.base.writeMathML(b)
+	if s.sub != nil {
+		s.sub.writeMathML(b)
+	}
+	if s.sup != nil {
+		s.sup.writeMathML(b)
+	}
+	b.WriteString("</" + tag + ">")
+}
+
+// Returns n as text, in parentheses if it's more than one character.
+func groupText(n node) string {
+	var b strings.Builder
+	n.writeText(&b)
+	s := strings.TrimSpace(b.String())
+	if utf8.RuneCountInString(s) > 1 {
+		if _, ok := n.(fenced); !ok {
+			return "(" + s + ")"
+		}
+	}
+	return s
+}
+
+// Returns s in superscript or subscript characters, or "" if some
+// character has no such form.
+func scriptText(s string, forms map[rune]rune) string {
+	var b strings.Builder
+	for _, r := range s {
+		f, ok := forms[r]
+		if !ok {
+			return ""
+		}
+		b.WriteRune(f)
+	}
+	return b.String()
+}
+
+func (s script) writeText(b *strings.Builder) {
+	s.base.writeText(b)
+	for _, part := range []struct {
+		n      node
+		forms  map[rune]rune
+		prefix string
+	}{{s.sub, subscripts, "_"}, {s.sup, superscripts, "^"}} {
+		if part.n == nil {
+			continue
+		}
+		var pb strings.Builder
+		part.n.writeText(&pb)
+		text := strings.Replace(pb.String(), " ", "", -1)
+		if t := scriptText(text, part.forms); t != "" {
+			b.WriteString(t)
+		} else {
+			b.WriteString(part.prefix + groupText(part.n))
+		}
+	}
+}
+
+func (f fraction) writeMathML(b *strings.Builder) {
+	if f.binom {
+		b.WriteString(`<mrow><mo>(</mo><mfrac linethickness="0">`)
+	} else {
+		b.WriteString("<mfrac>")
+	}
+	f.num.writeMathML(b)
+	f.den.writeMathML(b)
+	b.WriteString("</mfrac>")
+	if f.binom {
+		b.WriteString("<mo>)</mo></mrow>")
+	}
+}
+
+func (f fraction) writeText(b *strings.Builder) {
+	if f.binom {
+		b.WriteString("C(" + groupText(f.num) + ", " + groupText(f.den) + ")")
+		return
+	}
+	b.WriteString(groupText(f.num) + "/" + groupText(f.den))
+}
+
+func (r root) writeMathML(b *strings.Builder) {
+	if r.index == nil {
+		b.WriteString("<msqrt>")
+		r.radicand.writeMathML(b)
+		b.WriteString("</msqrt>")
+		return
+	}
+	b.WriteString("<mroot>")
+	r.radicand.writeMathML(b)
+	r.index.writeMathML(b)
+	b.WriteString("</mroot>")
+}
+
+func (r root) writeText(b *strings.Builder) {
+	sign := "√"
+	if r.index != nil {
+		switch index := groupText(r.index); index {
+		case "3":
+			sign = "∛"
+		case "4":
+			sign = "∜"
+		default:
+			if t := scriptText(index, superscripts); t != "" {
+				sign = t + "√"
+			} else {
+				sign = "root " + index + " of "
+			}
+		}
+	}
+	b.WriteString(sign + groupText(r.radicand))
+}
+
+func (f fenced) writeMathML(b *strings.Builder) {
+	b.WriteString("<mrow>")
+	if f.open != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` +
+			html.EscapeString(f.open) + "</mo>")
+	}
+	for _, n := range f.body {
+		n.writeMathML(b)
+	}
+	if f.close != "" {
+		b.WriteString(`<mo fence="true" stretchy="true">` +
+			html.EscapeString(f.close) + "</mo>")
+	}
+	b.WriteString("</mrow>")
+}
+
+func (f fenced) writeText(b *strings.Builder) {
+	b.WriteString(f.open)
+	f.body.writeText(b)
+	b.WriteString(f.close)
+}
+
+func (a accent) writeMathML(b *strings.Builder) {
+	tag, attr := "mover", "accent"
+	if a.under {
+		tag, attr = "munder", "accentunder"
+	}
+	b.WriteString("<" + tag + " " + attr + `="true">`)
+	a.base.writeMathML(b)
+	b.WriteString("<mo>" + html.EscapeString(a.over) + "</mo></" + tag + ">")
+}
+
+func (a accent) writeText(b *strings.Builder) {
+	var base strings.Builder
+	a.base.writeText(&base)
+	b.WriteString(base.String())
+	if utf8.RuneCountInString(base.String()) == 1 {
+		b.WriteString(a.combining)
+	}
+}
+
+func (s space) writeMathML(b *strings.Builder) {
+	b.WriteString(`<mspace width="` + s.width + `"/>`)
+}
+
+func (s space) writeText(b *strings.Builder) {
+	if !strings.HasPrefix(s.width, "-") {
+		b.WriteString(" ")
+	}
+}
+
+// Converts LaTeX math to a MathML <math> element, keeping the LaTeX as
+// an annotation. Returns an error if it uses anything unsupported, such
+// as environments or unknown commands.
+func ToMathML(tex string, display bool) (string, error) {
+	r, err := parse(tex, display)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString("><semantics>")
+	// Always one element, as <semantics> needs.
+	if len(r) == 1 {
+		b.WriteString("<mrow>")
+		r.writeMathML(&b)
+		b.WriteString("</mrow>")
+	} else {
+		r.writeMathML(&b)
+	}
+	b.WriteString(`<annotation encoding="application/x-tex">` +
+		html.EscapeString(strings.TrimSpace(tex)) + "</annotation></semantics></math>")
+	return b.String(), nil
+}
+
+// Converts LaTeX math to plain Unicode text, like "x² + α/2".
+func ToText(tex string) (string, error) {
+	r, err := parse(tex, false)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	r.writeText(&b)
+	return strings.Join(strings.Fields(b.String()), " "), nil
+}
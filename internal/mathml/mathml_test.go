@@ -0,0 +1,98 @@
+package mathml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToMathML(t *testing.T) {
+	for _, tc := range []struct {
+		tex      string
+		display  bool
+		expected string
+	}{
+		{`x`, false, `<mi>x</mi>`},
+		{`x^2 + 1`, false,
+			`<msup><mi>x</mi><mn>2</mn></msup><mo>+</mo><mn>1</mn>`},
+		{`a_{i,j}'`, false,
+			`<msubsup><mi>a</mi><mrow><mi>i</mi><mo>,</mo><mi>j</mi></mrow><mo>′</mo></msubsup>`},
+		{`\frac{-b \pm \sqrt{b^2-4ac}}{2a}`, true,
+			`<mfrac><mrow><mo>−</mo><mi>b</mi><mo>±</mo><msqrt><mrow>` +
+				`<msup><mi>b</mi><mn>2</mn></msup><mo>−</mo><mn>4</mn><mi>a</mi>` +
+				`<mi>c</mi></mrow></msqrt></mrow><mrow><mn>2</mn><mi>a</mi></mrow></mfrac>`},
+		{`\sum_{i=1}^n i`, true,
+			`<munderover><mo largeop="true">∑</mo><mrow><mi>i</mi><mo>=</mo>` +
+				`<mn>1</mn></mrow><mi>n</mi></munderover><mi>i</mi>`},
+		{`\sum_{i=1}^n i`, false,
+			`<msubsup><mo largeop="true">∑</mo><mrow><mi>i</mi><mo>=</mo>` +
+				`<mn>1</mn></mrow><mi>n</mi></msubsup><mi>i</mi>`},
+		{`\left( \frac{a}{b} \right]`, false,
+			`<mrow><mo fence="true" stretchy="true">(</mo><mfrac><mi>a</mi>` +
+				`<mi>b</mi></mfrac><mo fence="true" stretchy="true">]</mo></mrow>`},
+		{`\mathbb{R}^n \to \Gamma`, false,
+			`<msup><mi>ℝ</mi><mi>n</mi></msup><mo>→</mo><mi mathvariant="normal">Γ</mi>`},
+		{`\text{if } x < 3.14`, false,
+			`<mtext>if </mtext><mi>x</mi><mo>&lt;</mo><mn>3.14</mn>`},
+		{`\sqrt[3]{x}\,\hat{y}`, false,
+			`<mroot><mi>x</mi><mn>3</mn></mroot><mspace width="0.1667em"/>` +
+				`<mover accent="true"><mi>y</mi><mo>^</mo></mover>`},
+	} {
+		actual, err := ToMathML(tc.tex, tc.display)
+		if err != nil {
+			t.Errorf("ToMathML(%q) returned unexpected error: %v", tc.tex, err)
+			continue
+		}
+		if !strings.Contains(actual, "<semantics><mrow>"+tc.expected+"</mrow><annotation") {
+			t.Errorf("ToMathML(%q) returned:\n%s\nnot containing:\n%s",
+				tc.tex, actual, tc.expected)
+		}
+	}
+
+	actual, _ := ToMathML(`a<b`, true)
+	expected := `<math xmlns="http://www.w3.org/1998/Math/MathML" display="block">` +
+		`<semantics><mrow><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow>` +
+		`<annotation encoding="application/x-tex">a&lt;b</annotation></semantics></math>`
+	if actual != expected {
+		t.Errorf("ToMathML returned:\n%s\nnot:\n%s", actual, expected)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	for tex, expected := range map[string]string{
+		`\begin{matrix} a \end{matrix}`: `unsupported command \begin`,
+		`a & b \\ c`:                    `unsupported '&'`,
+		`\frac{a}`:                      `missing argument`,
+		`x^2^3`:                         `double superscript`,
+		`\left( x`:                      `\left without \right`,
+		`{x`:                            `missing }`,
+		`x}`:                            `unexpected "}"`,
+	} {
+		_, err := ToMathML(tex, false)
+		if err == nil || err.Error() != expected {
+			t.Errorf("ToMathML(%q) returned error %v, not %q", tex, err, expected)
+		}
+	}
+}
+
+func TestToText(t *testing.T) {
+	for tex, expected := range map[string]string{
+		`x^2 + y_1`:                     "x² + y₁",
+		`e^{i\pi} = -1`:                 "e^(iπ) = −1",
+		`x^{n-1}`:                       "xⁿ⁻¹",
+		`x^{ab}`:                        "x^(ab)",
+		`\frac{a+b}{2}`:                 "(a + b)/2",
+		`\sqrt{2}\cdot\sqrt[3]x`:        "√2 ⋅ ∛x",
+		`\alpha \leq \beta`:             "α ≤ β",
+		`\mathbb{N} \subset \mathbb{R}`: "ℕ ⊂ ℝ",
+		`\left(\frac{1}{2}\right)`:      "(1/2)",
+		`\text{for all } x`:             "for all x",
+		`\vec{v}`:                       "v⃗",
+	} {
+		actual, err := ToText(tex)
+		if err != nil {
+			t.Errorf("ToText(%q) returned unexpected error: %v", tex, err)
+		} else if actual != expected {
+			t.Errorf("ToText(%q) = %q, not %q", tex, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package mathml
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type parser struct {
+	src     string
+	pos     int
+	display bool
+}
+
+// Parses LaTeX math into a row of nodes.
+func parse(tex string, display bool) (row, error) {
+	p := &parser{src: tex, display: display}
+	r, err := p.row("")
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q", p.src[p.pos:p.pos+1])
+	}
+	return r, nil
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// Returns the next rune without consuming it, or 0 at the end.
+func (p *parser) peek() rune {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return r
+}
+
+func (p *parser) next() rune {
+	r, n := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += n
+	return r
+}
+
+// Returns the name of the command at the current position, just after
+// its backslash, without consuming it.
+func (p *parser) peekCommand() string {
+	if p.peek() != '\\' || p.pos+1 >= len(p.src) {
+		return ""
+	}
+	i := p.pos + 1
+	for i < len(p.src) && isLetter(p.src[i]) {
+		i++
+	}
+	if i == p.pos+1 {
+		i++ // A one-character command like \, or \{.
+	}
+	return p.src[p.pos+1 : i]
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// Parses nodes up to a closing brace (left unconsumed), the end, or, if
+// stop isn't "", that command.
+func (p *parser) row(stop string) (row, error) {
+	r := row{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) || p.peek() == '}' {
+			return r, nil
+		}
+		if stop != "" && p.peekCommand() == stop {
+			return r, nil
+		}
+		n, err := p.atom()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			r = append(r, n)
+		}
+	}
+}
+
+// Parses a base and any scripts on it.
+func (p *parser) atom() (node, error) {
+	base, limits, err := p.base()
+	if err != nil || base == nil {
+		return base, err
+	}
+	s := script{base: base, limits: limits && p.display}
+	for {
+		p.skipSpace()
+		c := p.peek()
+		if c != '^' && c != '_' && c != '\'' {
+			break
+		}
+		p.next()
+		if c == '\'' {
+			// Primes are superscripts.
+			prime := token{tag: "mo", text: "′"}
+			if s.sup == nil {
+				s.sup = prime
+			} else {
+				s.sup = row{s.sup, prime}
+			}
+			continue
+		}
+		arg, err := p.arg()
+		if err != nil {
+			return nil, err
+		}
+		if c == '^' {
+			if s.sup != nil {
+				return nil, fmt.Errorf("double superscript")
+			}
+			s.sup = arg
+		} else {
+			if s.sub != nil {
+				return nil, fmt.Errorf("double subscript")
+			}
+			s.sub = arg
+		}
+	}
+	if s.sub == nil && s.sup == nil {
+		return base, nil
+	}
+	return s, nil
+}
+
+// Parses the argument of a command or script: a group in braces, a
+// command, or a single character.
+func (p *parser) arg() (node, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == 0:
+		return nil, fmt.Errorf("missing argument")
+	case c == '{':
+		return p.group()
+	case c == '\\':
+		n, _, err := p.base()
+		if err == nil && n == nil {
+			err = fmt.Errorf("missing argument")
+		}
+		return n, err
+	case '0' <= c && c <= '9':
+		p.next()
+		return token{tag: "mn", text: string(c)}, nil
+	default:
+		n, _, err := p.base()
+		return n, err
+	}
+}
+
+// Parses a group in braces.
+func (p *parser) group() (row, error) {
+	p.skipSpace()
+	if p.peek() != '{' {
+		return nil, fmt.Errorf("expected {")
+	}
+	p.next()
+	r, err := p.row("")
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != '}' {
+		return nil, fmt.Errorf("missing }")
+	}
+	p.next()
+	return r, nil
+}
+
+// Reads the raw text of a group in braces, for \text.
+func (p *parser) rawGroup() (string, error) {
+	p.skipSpace()
+	if p.peek() != '{' {
+		return "", fmt.Errorf("expected {")
+	}
+	start, depth := p.pos+1, 0
+	for p.pos < len(p.src) {
+		switch p.next() {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return p.src[start : p.pos-1], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("missing }")
+}
+
+// Parses a delimiter after \left or \right: a character, a command
+// like \langle, or "." for none.
+func (p *parser) delimiter() (string, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '.':
+		p.next()
+		return "", nil
+	case c == '\\':
+		name := p.peekCommand()
+		if s, ok := symbols[name]; ok && s.tag == "mo" {
+			p.pos += 1 + len(name)
+			return s.text, nil
+		}
+		return "", fmt.Errorf("unsupported delimiter \\%s", name)
+	case c != 0 && strings.ContainsRune("()[]|/", c):
+		p.next()
+		return string(c), nil
+	}
+	return "", fmt.Errorf("missing delimiter")
+}
+
+// Parses a base: a group, number, identifier, operator or command.
+// Returns whether scripts on it go below and above in display math, and
+// nil for commands that produce nothing.
+func (p *parser) base() (node, bool, error) {
+	p.skipSpace()
+	c := p.peek()
+	switch {
+	case c == '{':
+		r, err := p.group()
+		return r, false, err
+	case c == '^' || c == '_':
+		return row{}, false, nil // As in {}^2.
+	case c == '\\':
+		return p.command()
+	case c == '&' || c == '#' || c == '%':
+		return nil, false, fmt.Errorf("unsupported %q", c)
+	case c == '~':
+		p.next()
+		return space{"0.25em"}, false, nil
+	case '0' <= c && c <= '9' || c == '.' && p.pos+1 < len(p.src) &&
+		'0' <= p.src[p.pos+1] && p.src[p.pos+1] <= '9':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '.' ||
+			'0' <= p.src[p.pos] && p.src[p.pos] <= '9') {
+			p.pos++
+		}
+		return token{tag: "mn", text: p.src[start:p.pos]}, false, nil
+	case strings.ContainsRune(operatorChars, c):
+		p.next()
+		t := token{tag: "mo", text: string(c)}
+		switch c {
+		case '-':
+			t.text = "−"
+		case '*':
+			t.text = "∗"
+		case '(', ')', '[', ']', '|':
+			t.attrs = ` stretchy="false"`
+		}
+		return t, false, nil
+	default:
+		p.next()
+		return token{tag: "mi", text: string(c)}, false, nil
+	}
+}
+
+// Parses a command, after its backslash.
+func (p *parser) command() (node, bool, error) {
+	name := p.peekCommand()
+	if name == "" {
+		return nil, false, fmt.Errorf("missing command after \\")
+	}
+	p.pos += 1 + len(name)
+
+	if s, ok := symbols[name]; ok {
+		return token{tag: s.tag, text: s.text}, false, nil
+	}
+	if s, ok := upperGreek[name]; ok {
+		return token{tag: "mi", text: s, attrs: ` mathvariant="normal"`}, false, nil
+	}
+	if op, ok := largeOperators[name]; ok {
+		return token{tag: "mo", text: op.text, attrs: ` largeop="true"`}, op.limits, nil
+	}
+	if limits, ok := functions[name]; ok {
+		return token{tag: "mi", text: name}, limits, nil
+	}
+	if a, ok := accents[name]; ok {
+		arg, err := p.arg()
+		return accent{base: arg, over: a.over, combining: a.combining}, false, err
+	}
+	if w, ok := spaces[name]; ok {
+		return space{w}, false, nil
+	}
+	if ignored[name] {
+		return nil, false, nil
+	}
+	if a, ok := alphabets[name]; ok {
+		arg, err := p.arg()
+		if err != nil {
+			return nil, false, err
+		}
+		return mapTokens(arg, func(t token) token {
+			t.text = strings.Map(a.convert, t.text)
+			return t
+		}), false, nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "binom":
+		num, err := p.arg()
+		if err != nil {
+			return nil, false, err
+		}
+		den, err := p.arg()
+		if err != nil {
+			return nil, false, err
+		}
+		return fraction{num: num, den: den, binom: name == "binom"}, false, nil
+
+	case "sqrt":
+		var index node
+		p.skipSpace()
+		if p.peek() == '[' {
+			p.next()
+			var r row
+			for p.peek() != ']' {
+				if p.peek() == 0 {
+					return nil, false, fmt.Errorf("missing ]")
+				}
+				n, err := p.atom()
+				if err != nil {
+					return nil, false, err
+				}
+				if n != nil {
+					r = append(r, n)
+				}
+				p.skipSpace()
+			}
+			p.next()
+			index = r
+		}
+		radicand, err := p.arg()
+		return root{index: index, radicand: radicand}, false, err
+
+	case "text", "textrm", "textit", "textbf", "mbox":
+		s, err := p.rawGroup()
+		return token{tag: "mtext", text: s}, false, err
+
+	case "operatorname":
+		s, err := p.rawGroup()
+		return token{tag: "mi", text: s}, false, err
+
+	case "mathrm", "mathit":
+		arg, err := p.arg()
+		if err != nil {
+			return nil, false, err
+		}
+		variant := ` mathvariant="normal"`
+		if name == "mathit" {
+			variant = ` mathvariant="italic"`
+		}
+		return mapTokens(arg, func(t token) token {
+			if t.tag == "mi" {
+				t.attrs = variant
+			}
+			return t
+		}), false, nil
+
+	case "underline":
+		arg, err := p.arg()
+		return accent{base: arg, over: "_", under: true}, false, err
+
+	case "left":
+		open, err := p.delimiter()
+		if err != nil {
+			return nil, false, err
+		}
+		body, err := p.row("right")
+		if err != nil {
+			return nil, false, err
+		}
+		if p.peekCommand() != "right" {
+			return nil, false, fmt.Errorf("\\left without \\right")
+		}
+		p.pos += len(`\right`)
+		close, err := p.delimiter()
+		return fenced{open: open, close: close, body: body}, false, err
+
+	case "right":
+		return nil, false, fmt.Errorf("\\right without \\left")
+	}
+	return nil, false, fmt.Errorf("unsupported command \\%s", name)
+}
+
+// Returns n with fn applied to each of its tokens.
+func mapTokens(n node, fn func(token) token) node {
+	switch n := n.(type) {
+	case token:
+		return fn(n)
+	case row:
+		r := make(row, len(n))
+		for i, child := range n {
+			r[i] = mapTokens(child, fn)
+		}
+		return r
+	case script:
+		n.base = mapTokens(n.base, fn)
+		return n
+	}
+	return n
+}
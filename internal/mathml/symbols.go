@@ -0,0 +1,150 @@
+package mathml
+
+// A symbol a command stands for.
+type symbol struct {
+	tag  string // "mi" or "mo"
+	text string
+}
+
+// Commands for single symbols.
+var symbols = map[string]symbol{
+	// Lowercase Greek.
+	"alpha": {"mi", "α"}, "beta": {"mi", "β"}, "gamma": {"mi", "γ"},
+	"delta": {"mi", "δ"}, "epsilon": {"mi", "ϵ"}, "varepsilon": {"mi", "ε"},
+	"zeta": {"mi", "ζ"}, "eta": {"mi", "η"}, "theta": {"mi", "θ"},
+	"vartheta": {"mi", "ϑ"}, "iota": {"mi", "ι"}, "kappa": {"mi", "κ"},
+	"lambda": {"mi", "λ"}, "mu": {"mi", "μ"}, "nu": {"mi", "ν"},
+	"xi": {"mi", "ξ"}, "pi": {"mi", "π"}, "varpi": {"mi", "ϖ"},
+	"rho": {"mi", "ρ"}, "varrho": {"mi", "ϱ"}, "sigma": {"mi", "σ"},
+	"varsigma": {"mi", "ς"}, "tau": {"mi", "τ"}, "upsilon": {"mi", "υ"},
+	"phi": {"mi", "ϕ"}, "varphi": {"mi", "φ"}, "chi": {"mi", "χ"},
+	"psi": {"mi", "ψ"}, "omega": {"mi", "ω"},
+
+	// Letter-like symbols.
+	"infty": {"mi", "∞"}, "partial": {"mi", "∂"}, "nabla": {"mi", "∇"},
+	"ell": {"mi", "ℓ"}, "hbar": {"mi", "ℏ"}, "emptyset": {"mi", "∅"},
+	"varnothing": {"mi", "∅"}, "aleph": {"mi", "ℵ"}, "Re": {"mi", "ℜ"},
+	"Im": {"mi", "ℑ"},
+
+	// Binary operators.
+	"pm": {"mo", "±"}, "mp": {"mo", "∓"}, "times": {"mo", "×"},
+	"div": {"mo", "÷"}, "cdot": {"mo", "⋅"}, "ast": {"mo", "∗"},
+	"star": {"mo", "⋆"}, "circ": {"mo", "∘"}, "bullet": {"mo", "∙"},
+	"oplus": {"mo", "⊕"}, "otimes": {"mo", "⊗"}, "cup": {"mo", "∪"},
+	"cap": {"mo", "∩"}, "setminus": {"mo", "∖"}, "wedge": {"mo", "∧"},
+	"land": {"mo", "∧"}, "vee": {"mo", "∨"}, "lor": {"mo", "∨"},
+	"neg": {"mo", "¬"}, "lnot": {"mo", "¬"},
+
+	// Relations.
+	"leq": {"mo", "≤"}, "le": {"mo", "≤"}, "geq": {"mo", "≥"},
+	"ge": {"mo", "≥"}, "neq": {"mo", "≠"}, "ne": {"mo", "≠"},
+	"approx": {"mo", "≈"}, "equiv": {"mo", "≡"}, "sim": {"mo", "∼"},
+	"simeq": {"mo", "≃"}, "cong": {"mo", "≅"}, "propto": {"mo", "∝"},
+	"ll": {"mo", "≪"}, "gg": {"mo", "≫"}, "in": {"mo", "∈"},
+	"notin": {"mo", "∉"}, "ni": {"mo", "∋"}, "subset": {"mo", "⊂"},
+	"supset": {"mo", "⊃"}, "subseteq": {"mo", "⊆"}, "supseteq": {"mo", "⊇"},
+	"mid": {"mo", "∣"}, "parallel": {"mo", "∥"}, "perp": {"mo", "⊥"},
+	"to": {"mo", "→"}, "rightarrow": {"mo", "→"}, "leftarrow": {"mo", "←"},
+	"gets": {"mo", "←"}, "leftrightarrow": {"mo", "↔"},
+	"Rightarrow": {"mo", "⇒"}, "Leftarrow": {"mo", "⇐"},
+	"Leftrightarrow": {"mo", "⇔"}, "implies": {"mo", "⟹"},
+	"iff": {"mo", "⟺"}, "mapsto": {"mo", "↦"}, "forall": {"mo", "∀"},
+	"exists": {"mo", "∃"},
+
+	// Dots and delimiters.
+	"ldots": {"mo", "…"}, "dots": {"mo", "…"}, "cdots": {"mo", "⋯"},
+	"vdots": {"mo", "⋮"}, "ddots": {"mo", "⋱"}, "langle": {"mo", "⟨"},
+	"rangle": {"mo", "⟩"}, "lfloor": {"mo", "⌊"}, "rfloor": {"mo", "⌋"},
+	"lceil": {"mo", "⌈"}, "rceil": {"mo", "⌉"}, "vert": {"mo", "|"},
+	"Vert": {"mo", "‖"}, "lvert": {"mo", "|"}, "rvert": {"mo", "|"},
+	"lVert": {"mo", "‖"}, "rVert": {"mo", "‖"},
+	"{": {"mo", "{"}, "}": {"mo", "}"}, "|": {"mo", "‖"},
+
+	// Escaped characters.
+	"%": {"mo", "%"}, "$": {"mi", "$"}, "#": {"mi", "#"}, "&": {"mo", "&"},
+	"_": {"mi", "_"},
+}
+
+// Uppercase Greek letters, which are upright.
+var upperGreek = map[string]string{
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+}
+
+// Large operators, and whether their scripts go above and below in
+// display math.
+var largeOperators = map[string]struct {
+	text   string
+	limits bool
+}{
+	"sum": {"∑", true}, "prod": {"∏", true}, "coprod": {"∐", true},
+	"bigcup": {"⋃", true}, "bigcap": {"⋂", true}, "bigoplus": {"⨁", true},
+	"bigotimes": {"⨂", true}, "bigvee": {"⋁", true}, "bigwedge": {"⋀", true},
+	"int": {"∫", false}, "iint": {"∬", false}, "iiint": {"∭", false},
+	"oint": {"∮", false},
+}
+
+// Named functions, and whether their scripts go above and below in
+// display math.
+var functions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "cot": false, "sec": false,
+	"csc": false, "arcsin": false, "arccos": false, "arctan": false,
+	"sinh": false, "cosh": false, "tanh": false, "log": false, "ln": false,
+	"lg": false, "exp": false, "arg": false, "deg": false, "dim": false,
+	"ker": false, "hom": false,
+	"lim": true, "liminf": true, "limsup": true, "max": true, "min": true,
+	"sup": true, "inf": true, "det": true, "gcd": true, "Pr": true,
+	"argmax": true, "argmin": true,
+}
+
+// Accents, as combining characters over a single letter in text, and
+// as characters over anything in MathML.
+var accents = map[string]struct{ combining, over string }{
+	"hat": {"̂", "^"}, "widehat": {"̂", "^"},
+	"bar": {"̄", "¯"}, "overline": {"̅", "¯"},
+	"vec": {"⃗", "→"}, "tilde": {"̃", "~"},
+	"widetilde": {"̃", "~"}, "dot": {"̇", "˙"},
+	"ddot": {"̈", "¨"},
+}
+
+// Spacing commands, as MathML widths.
+var spaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ";": "0.2778em", "!": "-0.1667em",
+	" ": "0.25em", "quad": "1em", "qquad": "2em",
+}
+
+// Commands that only affect spacing or size, and are ignored.
+var ignored = map[string]bool{
+	"displaystyle": true, "textstyle": true, "limits": true,
+	"nolimits": true, "big": true, "Big": true, "bigg": true, "Bigg": true,
+	"bigl": true, "bigr": true, "Bigl": true, "Bigr": true,
+}
+
+// Characters that are operators.
+const operatorChars = "+-=<>,;:!?()[]|/*'."
+
+// Operators spaced out in text.
+var spacedOperators = map[string]bool{
+	"+": true, "−": true, "=": true, "<": true, ">": true, "±": true,
+	"∓": true, "×": true, "÷": true, "⋅": true, "≤": true, "≥": true,
+	"≠": true, "≈": true, "≡": true, "∼": true, "≃": true, "≅": true,
+	"∝": true, "∈": true, "∉": true, "⊂": true, "⊃": true, "⊆": true,
+	"⊇": true, "→": true, "←": true, "↔": true, "⇒": true, "⇐": true,
+	"⇔": true, "⟹": true, "⟺": true, "↦": true, "∪": true, "∩": true,
+}
+
+// Superscript and subscript forms of characters, for text.
+var (
+	superscripts = map[rune]rune{
+		'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶',
+		'7': '⁷', '8': '⁸', '9': '⁹', '+': '⁺', '−': '⁻', '=': '⁼', '(': '⁽',
+		')': '⁾', 'n': 'ⁿ', 'i': 'ⁱ', '′': '′',
+	}
+	subscripts = map[rune]rune{
+		'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆',
+		'7': '₇', '8': '₈', '9': '₉', '+': '₊', '−': '₋', '=': '₌', '(': '₍',
+		')': '₎', 'a': 'ₐ', 'e': 'ₑ', 'i': 'ᵢ', 'j': 'ⱼ', 'k': 'ₖ', 'n': 'ₙ',
+		'o': 'ₒ', 'x': 'ₓ',
+	}
+)
@@ -0,0 +1,154 @@
+package metrics
+
+//
+// Request counts and latency histograms, exposed in the Prometheus text
+// format.
+//
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Upper bounds of the latency histogram buckets, in seconds.
+var buckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type countKey struct {
+	route, method string
+	code          int
+}
+
+type histogram struct {
+	counts []uint64 // One per bucket; not cumulative.
+	count  uint64
+	sum    float64
+}
+
+// Collects metrics for requests, by route.
+type Registry struct {
+	mu        sync.Mutex
+	counts    map[countKey]uint64
+	durations map[string]*histogram
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		counts:    make(map[countKey]uint64),
+		durations: make(map[string]*histogram),
+	}
+}
+
+// Records one request to route.
+func (reg *Registry) Observe(route, method string, code int, d time.Duration) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.counts[countKey{route, method, code}]++
+
+	h := reg.durations[route]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(buckets))}
+		reg.durations[route] = h
+	}
+	secs := d.Seconds()
+	for i, le := range buckets {
+		if secs <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += secs
+}
+
+// A ResponseWriter that records the status.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Wraps a handler so that its requests are recorded under route.
+func (reg *Registry) Instrument(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		h.ServeHTTP(rw, r)
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		reg.Observe(route, r.Method, rw.status, time.Since(start))
+	})
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Writes all metrics in the Prometheus text format.
+func (reg *Registry) Write(w io.Writer) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	keys := make([]countKey, 0, len(reg.counts))
+	for k := range reg.counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	fmt.Fprintln(w, "# HELP speakwrite_http_requests_total Requests served, by route, method and status code.")
+	fmt.Fprintln(w, "# TYPE speakwrite_http_requests_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "speakwrite_http_requests_total{route=%q,method=%q,code=\"%d\"} %d\n",
+			k.route, k.method, k.code, reg.counts[k])
+	}
+
+	routes := make([]string, 0, len(reg.durations))
+	for route := range reg.durations {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	fmt.Fprintln(w, "# HELP speakwrite_http_request_duration_seconds Time to serve requests, by route.")
+	fmt.Fprintln(w, "# TYPE speakwrite_http_request_duration_seconds histogram")
+	for _, route := range routes {
+		h := reg.durations[route]
+		var cumulative uint64
+		for i, le := range buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "speakwrite_http_request_duration_seconds_bucket{route=%q,le=%q} %d\n",
+				route, formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(w, "speakwrite_http_request_duration_seconds_bucket{route=%q,le=\"+Inf\"} %d\n",
+			route, h.count)
+		fmt.Fprintf(w, "speakwrite_http_request_duration_seconds_sum{route=%q} %s\n",
+			route, formatFloat(h.sum))
+		fmt.Fprintf(w, "speakwrite_http_request_duration_seconds_count{route=%q} %d\n",
+			route, h.count)
+	}
+	return nil
+}
+
+// Serves the metrics.
+func (reg *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	reg.Write(w)
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	reg := NewRegistry()
+	reg.Observe("/", "GET", 200, 3*time.Millisecond)
+	reg.Observe("/", "GET", 200, 200*time.Millisecond)
+	reg.Observe("/", "GET", 304, time.Millisecond)
+	reg.Observe("/posts/*filepath", "GET", 404, 20*time.Second)
+
+	var buf bytes.Buffer
+	if err := reg.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		`speakwrite_http_requests_total{route="/",method="GET",code="200"} 2`,
+		`speakwrite_http_requests_total{route="/",method="GET",code="304"} 1`,
+		`speakwrite_http_requests_total{route="/posts/*filepath",method="GET",code="404"} 1`,
+		`speakwrite_http_request_duration_seconds_bucket{route="/",le="0.005"} 2`,
+		`speakwrite_http_request_duration_seconds_bucket{route="/",le="0.1"} 2`,
+		`speakwrite_http_request_duration_seconds_bucket{route="/",le="0.25"} 3`,
+		`speakwrite_http_request_duration_seconds_bucket{route="/",le="+Inf"} 3`,
+		`speakwrite_http_request_duration_seconds_count{route="/"} 3`,
+		`speakwrite_http_request_duration_seconds_bucket{route="/posts/*filepath",le="10"} 0`,
+		`speakwrite_http_request_duration_seconds_bucket{route="/posts/*filepath",le="+Inf"} 1`,
+		`speakwrite_http_request_duration_seconds_sum{route="/posts/*filepath"} 20`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, out)
+		}
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	reg := NewRegistry()
+	h := reg.Instrument("/x", http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/x", nil))
+
+	var buf bytes.Buffer
+	reg.Write(&buf)
+	expected := `speakwrite_http_requests_total{route="/x",method="GET",code="404"} 1`
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("missing %q in:\n%s", expected, buf.String())
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/hblanks/speakwrite/internal/compress"
 	"github.com/hblanks/speakwrite/internal/web"
 )
 
@@ -25,7 +26,20 @@ func (r *responseWriter) Write(buf []byte) (int, error) {
 	return r.f.Write(buf)
 }
 
-func writeURL(s *web.Server, u, outputRoot string) error {
+// Overrides ResponseRecorder.WriteString, which io.WriteString would
+// otherwise use to bypass Write.
+func (r *responseWriter) WriteString(s string) (int, error) {
+	return r.f.WriteString(s)
+}
+
+// Options for rendering.
+type Options struct {
+	// Also write .gz and .br copies of text files, for servers that
+	// serve precompressed files.
+	Precompress bool
+}
+
+func writeURL(s *web.Server, u, outputRoot string, opts *Options) error {
 	parsedURL, err := url.Parse(u)
 	if err != nil {
 		return err
@@ -64,17 +78,36 @@ func writeURL(s *web.Server, u, outputRoot string) error {
 		return fmt.Errorf("URL %s returned %d, not 200!",
 			u, w.Result().StatusCode)
 	}
-	os.Rename(f.Name(), p)
+	if err := os.Rename(f.Name(), p); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if opts.Precompress && compress.CompressibleFile(p) {
+		return compress.WriteFiles(p)
+	}
 	return nil
 }
 
-func WriteURLs(s *web.Server, outputRoot string) error {
+// Writes every URL of the site to outputRoot. opts may be nil. Writes
+// as many as it can, and returns an error listing those it couldn't.
+func WriteURLs(s *web.Server, outputRoot string, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
 	urls, err := s.GetURLs()
 	if err != nil {
 		return err
 	}
+	var failures []string
 	for _, u := range urls {
-		writeURL(s, u, outputRoot)
+		if err := writeURL(s, u, outputRoot, opts); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d of %d URL(s) failed:\n%s",
+			len(failures), len(urls), strings.Join(failures, "\n"))
 	}
 	return nil
 }
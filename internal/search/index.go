@@ -0,0 +1,283 @@
+package search
+
+//
+// An in-memory inverted index of posts, for searching in serve mode.
+//
+
+import (
+	"html/template"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/hblanks/speakwrite/internal/content"
+	"github.com/hblanks/speakwrite/internal/text"
+)
+
+// BM25 ranking parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+	// Matches in the title count this many times over.
+	titleWeight = 3
+)
+
+// Words in a snippet.
+const snippetWords = 30
+
+type document struct {
+	post   *content.Post
+	text   string
+	words  []text.Word
+	stems  []string // Of words.
+	length int      // Words in the title and text.
+}
+
+// Where a word appears in a document. Positions number the words in the
+// title, then (after a gap, so that phrases can't span the two) those in
+// the text.
+type posting struct {
+	doc       int
+	positions []int
+	titleHits int
+}
+
+// A post matching a query.
+type Result struct {
+	Post  *content.Post
+	Score float64
+	// Text from the post around the first match, with matching words in
+	// <mark> tags.
+	Snippet template.HTML
+}
+
+type Index struct {
+	docs      []*document
+	postings  map[string][]*posting
+	avgLength float64
+}
+
+// Builds the index for posts.
+func NewIndex(posts []*content.Post) (*Index, error) {
+	idx := &Index{postings: make(map[string][]*posting)}
+	total := 0
+	for i, post := range posts {
+		body, err := post.PlainText()
+		if err != nil {
+			return nil, err
+		}
+		d := &document{post: post, text: body, words: text.Words(body)}
+		d.stems = make([]string, len(d.words))
+		for j, w := range d.words {
+			d.stems[j] = text.Stem(w.Text)
+		}
+		titleStems := stems(post.Title)
+		d.length = len(titleStems) + len(d.stems)
+		total += d.length
+		idx.docs = append(idx.docs, d)
+
+		current := make(map[string]*posting)
+		get := func(stem string) *posting {
+			p := current[stem]
+			if p == nil {
+				p = &posting{doc: i}
+				current[stem] = p
+				idx.postings[stem] = append(idx.postings[stem], p)
+			}
+			return p
+		}
+		for pos, stem := range titleStems {
+			p := get(stem)
+			p.positions = append(p.positions, pos)
+			p.titleHits++
+		}
+		offset := len(titleStems) + 1
+		for pos, stem := range d.stems {
+			p := get(stem)
+			p.positions = append(p.positions, offset+pos)
+		}
+	}
+	if len(posts) > 0 {
+		idx.avgLength = float64(total) / float64(len(posts))
+	}
+	return idx, nil
+}
+
+// Returns the posting for stem in doc, or nil.
+func (idx *Index) posting(stem string, doc int) *posting {
+	ps := idx.postings[stem]
+	i := sort.Search(len(ps), func(i int) bool { return ps[i].doc >= doc })
+	if i < len(ps) && ps[i].doc == doc {
+		return ps[i]
+	}
+	return nil
+}
+
+// Returns whether doc contains the phrase.
+func (idx *Index) hasPhrase(phrase []string, doc int) bool {
+	var ps []*posting
+	for _, stem := range phrase {
+		p := idx.posting(stem, doc)
+		if p == nil {
+			return false
+		}
+		ps = append(ps, p)
+	}
+	for _, start := range ps[0].positions {
+		found := true
+		for i, p := range ps[1:] {
+			j := sort.SearchInts(p.positions, start+i+1)
+			if j == len(p.positions) || p.positions[j] != start+i+1 {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether a post has the query's tags and series.
+func matchesFilters(q *Query, post *content.Post) bool {
+	for _, tag := range q.Tags {
+		found := false
+		for _, t := range post.Metadata.Tags {
+			if strings.ToLower(t) == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, series := range q.Series {
+		if post.Series.Name != series {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the posts matching q, best first, and at most limit of them if
+// limit > 0. Posts that score the same (as they all do for a query of only
+// tags and series) are newest first.
+func (idx *Index) Search(q *Query, limit int) []*Result {
+	if q.Empty() {
+		return nil
+	}
+	words := q.words()
+	matched := make(map[string]bool)
+	for _, w := range words {
+		matched[w] = true
+	}
+
+	var results []*Result
+	for i, d := range idx.docs {
+		if !matchesFilters(q, d.post) {
+			continue
+		}
+		score, ok := 0.0, true
+		for _, w := range words {
+			p := idx.posting(w, i)
+			if p == nil {
+				ok = false
+				break
+			}
+			score += idx.score(w, p, d)
+		}
+		for _, phrase := range q.Phrases {
+			if !ok {
+				break
+			}
+			ok = idx.hasPhrase(phrase, i)
+		}
+		if !ok {
+			continue
+		}
+		results = append(results, &Result{
+			Post:    d.post,
+			Score:   score,
+			Snippet: d.snippet(matched),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.Post.Date.Equal(b.Post.Date) {
+			return a.Post.Date.After(b.Post.Date)
+		}
+		return a.Post.RelativeURL() < b.Post.RelativeURL()
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Returns the BM25 score of a word in a document.
+func (idx *Index) score(stem string, p *posting, d *document) float64 {
+	n := float64(len(idx.docs))
+	df := float64(len(idx.postings[stem]))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	tf := float64(len(p.positions) + (titleWeight-1)*p.titleHits)
+	norm := 1 - b + b*float64(d.length)/idx.avgLength
+	return idf * tf * (k1 + 1) / (tf + k1*norm)
+}
+
+// Returns the text around the first word whose stem is in matched, with
+// all such words marked.
+func (d *document) snippet(matched map[string]bool) template.HTML {
+	first := 0
+	for i, stem := range d.stems {
+		if matched[stem] {
+			first = i
+			break
+		}
+	}
+	start := first - snippetWords/3
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetWords
+	if end > len(d.words) {
+		end = len(d.words)
+	}
+	if start == end {
+		return ""
+	}
+
+	// Writes text from between words.
+	var sb strings.Builder
+	writeBetween := func(s string) {
+		sb.WriteString(template.HTMLEscapeString(strings.ReplaceAll(s, "\n", " ")))
+	}
+	if start > 0 {
+		sb.WriteString("… ")
+	} else {
+		writeBetween(d.text[:d.words[0].Start])
+	}
+	for i := start; i < end; i++ {
+		w := d.words[i]
+		if i > start {
+			writeBetween(d.text[d.words[i-1].End:w.Start])
+		}
+		original := template.HTMLEscapeString(d.text[w.Start:w.End])
+		if matched[d.stems[i]] {
+			sb.WriteString("<mark>" + original + "</mark>")
+		} else {
+			sb.WriteString(original)
+		}
+	}
+	if end < len(d.words) {
+		sb.WriteString(" …")
+	} else {
+		writeBetween(d.text[d.words[end-1].End:])
+	}
+	return template.HTML(sb.String())
+}
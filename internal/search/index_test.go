@@ -0,0 +1,118 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hblanks/speakwrite/internal/content"
+)
+
+func TestParseQuery(t *testing.T) {
+	for s, expected := range map[string]*Query{
+		"":        {},
+		"Running": {Terms: []string{"run"}},
+		`go "error handling" tag:HTTP series:rust`: {
+			Terms:   []string{"go"},
+			Phrases: [][]string{{"error", "handl"}},
+			Tags:    []string{"http"},
+			Series:  []string{"rust"},
+		},
+		`"single" "unterminated phrase`: {
+			Terms:   []string{"singl"},
+			Phrases: [][]string{{"untermin", "phrase"}},
+		},
+		"tag: foo-bar": {Terms: []string{"tag", "foo", "bar"}},
+	} {
+		if actual := ParseQuery(s); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("ParseQuery(%q) = %+v, not %+v", s, actual, expected)
+		}
+	}
+}
+
+func newTestIndex(t *testing.T) *Index {
+	dir := t.TempDir()
+	base := &content.Series{}
+	rust := &content.Series{Name: "rust"}
+	var posts []*content.Post
+	for i, p := range []struct {
+		title, body string
+		series      *content.Series
+		tags        []string
+	}{
+		{"Error handling in Go", "Go handles errors as values. Handling errors is explicit.",
+			base, []string{"go"}},
+		{"Rust errors", "Rust handles errors with Result. Error handling uses the ? operator.",
+			rust, []string{"rust"}},
+		{"HTTP servers", "Writing an HTTP server in Go, with error pages and \"quotes\".",
+			base, []string{"go", "http"}},
+	} {
+		path := filepath.Join(dir, p.title+".md")
+		md := "% " + p.title + "\n\n" + p.body + "\n"
+		if err := os.WriteFile(path, []byte(md), 0644); err != nil {
+			t.Fatal(err)
+		}
+		posts = append(posts, &content.Post{
+			Name:        strings.ToLower(strings.Fields(p.title)[0]),
+			Title:       p.title,
+			Date:        time.Date(2020, 1, i+1, 0, 0, 0, 0, time.UTC),
+			ContentPath: path,
+			Series:      p.series,
+			Metadata:    content.PostMetadata{Tags: p.tags},
+		})
+	}
+	idx, err := NewIndex(posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func titles(results []*Result) []string {
+	var titles []string
+	for _, r := range results {
+		titles = append(titles, r.Post.Title)
+	}
+	return titles
+}
+
+func TestSearch(t *testing.T) {
+	idx := newTestIndex(t)
+	for q, expected := range map[string][]string{
+		"":                       nil,
+		"handled":                {"Error handling in Go", "Rust errors"},
+		"error":                  {"Rust errors", "Error handling in Go", "HTTP servers"},
+		`"error handling"`:       {"Error handling in Go", "Rust errors"},
+		`"explicit errors"`:      nil,
+		"error tag:http":         {"HTTP servers"},
+		"error series:rust":      {"Rust errors"},
+		"tag:go":                 {"HTTP servers", "Error handling in Go"},
+		"go http":                {"HTTP servers"},
+		"nonexistent":            nil,
+		`"handling in go" error`: {"Error handling in Go"},
+	} {
+		actual := titles(idx.Search(ParseQuery(q), 0))
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Search(%q) = %q, not %q", q, actual, expected)
+		}
+	}
+
+	if results := idx.Search(ParseQuery("error"), 1); len(results) != 1 {
+		t.Errorf("Search with limit 1 returned %d results", len(results))
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	idx := newTestIndex(t)
+	results := idx.Search(ParseQuery("pages"), 0)
+	if len(results) != 1 {
+		t.Fatalf("got %d results, not 1", len(results))
+	}
+	expected := "Writing an HTTP server in Go, with error <mark>pages</mark> and &#34;quotes&#34;."
+	if actual := string(results[0].Snippet); actual != expected {
+		t.Errorf("snippet is %q, not %q", actual, expected)
+	}
+}
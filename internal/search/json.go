@@ -0,0 +1,105 @@
+package search
+
+//
+// The search index that render writes out as /search-index.json, for
+// searching in the browser.
+//
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/hblanks/speakwrite/internal/content"
+	"github.com/hblanks/speakwrite/internal/text"
+)
+
+// Search index formats.
+const (
+	// Each post's text in full.
+	Full = "full"
+	// A map of words to the posts containing them.
+	Inverted = "inverted"
+)
+
+// One post in the search index.
+type Document struct {
+	URL         string   `json:"url"`
+	Title       string   `json:"title"`
+	Series      string   `json:"series,omitempty"`
+	SeriesTitle string   `json:"series_title,omitempty"`
+	Tags        []string `json:"tags"`
+	Date        string   `json:"date"`
+	Text        string   `json:"text,omitempty"`
+}
+
+// Returns the search document for a post at url.
+func NewDocument(post *content.Post, url string) (*Document, error) {
+	body, err := post.PlainText()
+	if err != nil {
+		return nil, err
+	}
+	d := &Document{
+		URL:    url,
+		Title:  post.Title,
+		Series: post.Series.Name,
+		Tags:   post.Metadata.Tags,
+		Date:   post.Date.Format(content.IsoDateFormat),
+		Text:   body,
+	}
+	if post.Series.Name != "" { // Exclude the base series
+		d.SeriesTitle = post.Series.Title
+	}
+	if d.Tags == nil {
+		d.Tags = []string{}
+	}
+	return d, nil
+}
+
+// A posting in the JSON index: the index of a document in Posts, and
+// how many times the word appears in it (counting its title twice).
+type jsonPosting [2]int
+
+type fullIndex struct {
+	Posts []*Document `json:"posts"`
+}
+
+type invertedIndex struct {
+	Posts []*Document              `json:"posts"`
+	Words map[string][]jsonPosting `json:"words"`
+}
+
+// Writes the search index for docs as JSON in the given format. In the
+// full format, each post has its text. In the inverted format, posts
+// have no text, and instead each word maps to [post index, count] pairs.
+func WriteJSON(w io.Writer, docs []*Document, format string) error {
+	if format != Inverted {
+		return json.NewEncoder(w).Encode(&fullIndex{Posts: docs})
+	}
+
+	idx := invertedIndex{
+		Posts: make([]*Document, len(docs)),
+		Words: make(map[string][]jsonPosting),
+	}
+	for i, d := range docs {
+		stripped := *d
+		stripped.Text = ""
+		idx.Posts[i] = &stripped
+
+		counts := make(map[string]int)
+		for _, word := range text.Tokenize(d.Title) {
+			counts[word] += 2
+		}
+		for _, word := range text.Tokenize(d.Text) {
+			counts[word]++
+		}
+		for word, n := range counts {
+			idx.Words[word] = append(idx.Words[word], jsonPosting{i, n})
+		}
+	}
+	return json.NewEncoder(w).Encode(&idx)
+}
+
+// Returns whether format is a known search index format.
+func Valid(format string) bool {
+	return format == Full || format == Inverted
+}
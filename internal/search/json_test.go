@@ -0,0 +1,45 @@
+package search
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testDocs = []*Document{
+	{URL: "/posts/a/", Title: "Apples", Tags: []string{}, Date: "2020-01-01",
+		Text: "Apples and pears."},
+	{URL: "/posts/b/", Title: "Pears", Tags: []string{"fruit"}, Date: "2020-01-02",
+		Text: "Pears, pears, pears."},
+}
+
+func TestWriteJSONFull(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, testDocs, Full); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"posts":[` +
+		`{"url":"/posts/a/","title":"Apples","tags":[],"date":"2020-01-01","text":"Apples and pears."},` +
+		`{"url":"/posts/b/","title":"Pears","tags":["fruit"],"date":"2020-01-02","text":"Pears, pears, pears."}` +
+		`]}`
+	if actual := strings.TrimSpace(buf.String()); actual != expected {
+		t.Errorf("WriteJSON wrote\n%s\nnot\n%s", actual, expected)
+	}
+}
+
+func TestWriteJSONInverted(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, testDocs, Inverted); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"posts":[` +
+		`{"url":"/posts/a/","title":"Apples","tags":[],"date":"2020-01-01"},` +
+		`{"url":"/posts/b/","title":"Pears","tags":["fruit"],"date":"2020-01-02"}` +
+		`],"words":{"and":[[0,1]],"apples":[[0,3]],"pears":[[0,1],[1,5]]}}`
+	if actual := strings.TrimSpace(buf.String()); actual != expected {
+		t.Errorf("WriteJSON wrote\n%s\nnot\n%s", actual, expected)
+	}
+	if testDocs[0].Text == "" {
+		t.Errorf("WriteJSON modified its documents")
+	}
+}
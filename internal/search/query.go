@@ -0,0 +1,95 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/hblanks/speakwrite/internal/text"
+)
+
+// A parsed search query. Posts match if they contain every term and
+// phrase, and have every tag and series given.
+type Query struct {
+	Terms   []string   // Stemmed words.
+	Phrases [][]string // Stemmed words that must appear in order.
+	Tags    []string   // Lowercased.
+	Series  []string   // Series names.
+}
+
+// Stems each word in s.
+func stems(s string) []string {
+	words := text.Tokenize(s)
+	for i, w := range words {
+		words[i] = text.Stem(w)
+	}
+	return words
+}
+
+// Parses a query such as `go "error handling" tag:http series:rust`.
+func ParseQuery(s string) *Query {
+	q := &Query{}
+	for s != "" {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			break
+		}
+		var field string
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				field, s = s[1:], ""
+			} else {
+				field, s = s[1:end+1], s[end+2:]
+			}
+			switch words := stems(field); len(words) {
+			case 0:
+			case 1:
+				q.Terms = append(q.Terms, words[0])
+			default:
+				q.Phrases = append(q.Phrases, words)
+			}
+			continue
+		}
+
+		if end := strings.IndexAny(s, " \t\r\n"); end < 0 {
+			field, s = s, ""
+		} else {
+			field, s = s[:end], s[end:]
+		}
+		switch {
+		case strings.HasPrefix(field, "tag:") && len(field) > 4:
+			q.Tags = append(q.Tags, strings.ToLower(field[4:]))
+		case strings.HasPrefix(field, "series:") && len(field) > 7:
+			q.Series = append(q.Series, field[7:])
+		default:
+			q.Terms = append(q.Terms, stems(field)...)
+		}
+	}
+	return q
+}
+
+// Returns whether the query has nothing to search for.
+func (q *Query) Empty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0 &&
+		len(q.Tags) == 0 && len(q.Series) == 0
+}
+
+// Returns every stemmed word in the terms and phrases, once each.
+func (q *Query) words() []string {
+	var words []string
+	seen := make(map[string]bool)
+	add := func(w string) {
+		if !seen[w] {
+			seen[w] = true
+			words = append(words, w)
+		}
+	}
+	for _, w := range q.Terms {
+		add(w)
+	}
+	for _, p := range q.Phrases {
+		for _, w := range p {
+			add(w)
+		}
+	}
+	return words
+}
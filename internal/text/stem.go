@@ -0,0 +1,274 @@
+package text
+
+//
+// The Porter stemming algorithm, for English, after Martin Porter's
+// reference implementation: https://tartarus.org/martin/PorterStemmer/
+//
+
+type stemmer struct {
+	b []byte
+	k int // The end of the stem, inclusive.
+	j int // The end of the stem that a suffix was found after.
+}
+
+// Returns whether b[i] is a consonant.
+func (z *stemmer) cons(i int) bool {
+	switch z.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		if i == 0 {
+			return true
+		}
+		return !z.cons(i - 1)
+	}
+	return true
+}
+
+// Returns the number of vowel-consonant sequences in b[0:j+1].
+func (z *stemmer) m() int {
+	n, i := 0, 0
+	for {
+		if i > z.j {
+			return n
+		}
+		if !z.cons(i) {
+			break
+		}
+		i++
+	}
+	i++
+	for {
+		for {
+			if i > z.j {
+				return n
+			}
+			if z.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+		n++
+		for {
+			if i > z.j {
+				return n
+			}
+			if !z.cons(i) {
+				break
+			}
+			i++
+		}
+		i++
+	}
+}
+
+// Returns whether b[0:j+1] contains a vowel.
+func (z *stemmer) vowelInStem() bool {
+	for i := 0; i <= z.j; i++ {
+		if !z.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether b[i-1:i+1] is a double consonant.
+func (z *stemmer) doublec(i int) bool {
+	return i >= 1 && z.b[i] == z.b[i-1] && z.cons(i)
+}
+
+// Returns whether b[i-2:i+1] is consonant-vowel-consonant, and the last
+// consonant isn't w, x or y, as in "hop" but not "snow".
+func (z *stemmer) cvc(i int) bool {
+	if i < 2 || !z.cons(i) || z.cons(i-1) || !z.cons(i-2) {
+		return false
+	}
+	switch z.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// Returns whether b[0:k+1] ends with s, and if so sets j to where s
+// starts, less one.
+func (z *stemmer) ends(s string) bool {
+	l := len(s)
+	if l > z.k+1 || string(z.b[z.k-l+1:z.k+1]) != s {
+		return false
+	}
+	z.j = z.k - l
+	return true
+}
+
+// Replaces b[j+1:k+1] with s.
+func (z *stemmer) setto(s string) {
+	z.b = append(z.b[:z.j+1], s...)
+	z.k = z.j + len(s)
+}
+
+// Replaces the suffix with s if the stem before it has m() > 0.
+func (z *stemmer) r(s string) {
+	if z.m() > 0 {
+		z.setto(s)
+	}
+}
+
+// Removes plurals and -ed or -ing.
+func (z *stemmer) step1ab() {
+	if z.b[z.k] == 's' {
+		switch {
+		case z.ends("sses"):
+			z.k -= 2
+		case z.ends("ies"):
+			z.setto("i")
+		case z.b[z.k-1] != 's':
+			z.k--
+		}
+	}
+	if z.ends("eed") {
+		if z.m() > 0 {
+			z.k--
+		}
+	} else if (z.ends("ed") || z.ends("ing")) && z.vowelInStem() {
+		z.k = z.j
+		switch {
+		case z.ends("at"):
+			z.setto("ate")
+		case z.ends("bl"):
+			z.setto("ble")
+		case z.ends("iz"):
+			z.setto("ize")
+		case z.doublec(z.k):
+			z.k--
+			switch z.b[z.k] {
+			case 'l', 's', 'z':
+				z.k++
+			}
+		default:
+			if z.m() == 1 && z.cvc(z.k) {
+				z.setto("e")
+			}
+		}
+	}
+}
+
+// Turns a terminal y into i when there's another vowel in the stem.
+func (z *stemmer) step1c() {
+	if z.ends("y") && z.vowelInStem() {
+		z.b[z.k] = 'i'
+	}
+}
+
+// Replaces the first of suffixes that the word ends with, as r does.
+func (z *stemmer) replaceFirst(suffixes [][2]string) {
+	for _, s := range suffixes {
+		if z.ends(s[0]) {
+			z.r(s[1])
+			return
+		}
+	}
+}
+
+var step2Suffixes = map[byte][][2]string{
+	'a': {{"ational", "ate"}, {"tional", "tion"}},
+	'c': {{"enci", "ence"}, {"anci", "ance"}},
+	'e': {{"izer", "ize"}},
+	'l': {{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"}},
+	'o': {{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}},
+	's': {{"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"}, {"ousness", "ous"}},
+	't': {{"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}},
+	'g': {{"logi", "log"}},
+}
+
+// Maps double suffixes to single ones, e.g. -ization to -ize.
+func (z *stemmer) step2() {
+	z.replaceFirst(step2Suffixes[z.b[z.k-1]])
+}
+
+var step3Suffixes = map[byte][][2]string{
+	'e': {{"icate", "ic"}, {"ative", ""}, {"alize", "al"}},
+	'i': {{"iciti", "ic"}},
+	'l': {{"ical", "ic"}, {"ful", ""}},
+	's': {{"ness", ""}},
+}
+
+// Handles -ic-, -full, -ness and so on.
+func (z *stemmer) step3() {
+	z.replaceFirst(step3Suffixes[z.b[z.k]])
+}
+
+var step4Suffixes = map[byte][]string{
+	'a': {"al"},
+	'c': {"ance", "ence"},
+	'e': {"er"},
+	'i': {"ic"},
+	'l': {"able", "ible"},
+	'n': {"ant", "ement", "ment", "ent"},
+	'o': {"ion", "ou"},
+	's': {"ism"},
+	't': {"ate", "iti"},
+	'u': {"ous"},
+	'v': {"ive"},
+	'z': {"ize"},
+}
+
+// Removes -ant, -ence and so on when the stem has m() > 1.
+func (z *stemmer) step4() {
+	for _, s := range step4Suffixes[z.b[z.k-1]] {
+		if !z.ends(s) {
+			continue
+		}
+		if s == "ion" && (z.j < 0 || (z.b[z.j] != 's' && z.b[z.j] != 't')) {
+			continue
+		}
+		if z.m() > 1 {
+			z.k = z.j
+		}
+		return
+	}
+}
+
+// Removes a final -e when m() > 1, and turns -ll into -l.
+func (z *stemmer) step5() {
+	z.j = z.k
+	if z.b[z.k] == 'e' {
+		a := z.m()
+		if a > 1 || a == 1 && !z.cvc(z.k-1) {
+			z.k--
+		}
+	}
+	if z.b[z.k] == 'l' && z.doublec(z.k) && z.m() > 1 {
+		z.k--
+	}
+}
+
+// Returns whether word is entirely lowercase ASCII letters.
+func isLowerASCII(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the stem of a lowercase English word, so that e.g. "connect",
+// "connected" and "connections" all become "connect". Words that aren't
+// all ASCII letters, or are shorter than three, are returned as is.
+func Stem(word string) string {
+	if len(word) <= 2 || !isLowerASCII(word) {
+		return word
+	}
+	z := &stemmer{b: []byte(word), k: len(word) - 1}
+	z.step1ab()
+	if z.k > 0 {
+		z.step1c()
+		z.step2()
+		z.step3()
+		z.step4()
+		z.step5()
+	}
+	return string(z.b[:z.k+1])
+}
@@ -0,0 +1,31 @@
+package text
+
+// Common English words that say little about what a text is about.
+var stopWords = make(map[string]bool)
+
+func init() {
+	for _, w := range []string{
+		"a", "about", "after", "all", "also", "am", "an", "and", "any",
+		"are", "as", "at", "be", "because", "been", "before", "being",
+		"but", "by", "can", "could", "did", "do", "does", "doing", "dont",
+		"down", "each", "few", "for", "from", "further", "had", "has",
+		"have", "having", "he", "her", "here", "hers", "him", "his", "how",
+		"i", "if", "in", "into", "is", "it", "its", "itself", "just",
+		"me", "more", "most", "my", "no", "nor", "not", "now", "of", "off",
+		"on", "once", "only", "or", "other", "our", "ours", "out", "over",
+		"own", "same", "she", "should", "so", "some", "such", "than",
+		"that", "the", "their", "theirs", "them", "then", "there", "these",
+		"they", "this", "those", "through", "to", "too", "under", "until",
+		"up", "very", "was", "we", "were", "what", "when", "where",
+		"which", "while", "who", "whom", "why", "will", "with", "would",
+		"you", "your", "yours",
+	} {
+		stopWords[w] = true
+	}
+}
+
+// Returns whether a lowercased word is too common to be worth matching
+// on by itself.
+func IsStopWord(word string) bool {
+	return stopWords[word]
+}
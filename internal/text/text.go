@@ -0,0 +1,74 @@
+package text
+
+//
+// Splits text into words, for search and similarity.
+//
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Returns whether r can be part of a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// Returns whether r is an apostrophe, which is dropped from within
+// words so that "don't" and "dont" match.
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
+
+// A word in some text.
+type Word struct {
+	Text       string // Lowercased, without apostrophes.
+	Start, End int    // Byte offsets of the original in the text.
+}
+
+// Splits s into words. Everything that isn't a letter or digit separates
+// words, except apostrophes within them.
+func Words(s string) []Word {
+	var words []Word
+	var b strings.Builder
+	start, prev := -1, rune(0)
+	for i, r := range s {
+		switch {
+		case isWordRune(r):
+			if start < 0 {
+				start = i
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case isApostrophe(r) && start >= 0:
+			// Drop it, and keep going if a letter follows.
+		case start >= 0:
+			words = append(words, Word{b.String(), start, wordEnd(s, i, prev)})
+			b.Reset()
+			start = -1
+		}
+		prev = r
+	}
+	if start >= 0 {
+		words = append(words, Word{b.String(), start, wordEnd(s, len(s), prev)})
+	}
+	return words
+}
+
+// Returns the end of a word that stopped at offset i, after rune prev,
+// leaving out a trailing apostrophe.
+func wordEnd(s string, i int, prev rune) int {
+	if isApostrophe(prev) {
+		return i - utf8.RuneLen(prev)
+	}
+	return i
+}
+
+// Splits s into lowercased words, as Words does.
+func Tokenize(s string) []string {
+	var tokens []string
+	for _, w := range Words(s) {
+		tokens = append(tokens, w.Text)
+	}
+	return tokens
+}
@@ -0,0 +1,92 @@
+package text
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	for s, expected := range map[string][]string{
+		"":                         nil,
+		"  ":                       nil,
+		"Hello, world!":            {"hello", "world"},
+		"don't stop":               {"dont", "stop"},
+		"rock ’n’ roll":            {"rock", "n", "roll"},
+		"'quoted'":                 {"quoted"},
+		"Go 1.13 and HTTP/2":       {"go", "1", "13", "and", "http", "2"},
+		"naïve café Über":          {"naïve", "café", "über"},
+		"snake_case-and-kebab":     {"snake", "case", "and", "kebab"},
+		"trailing apostrophe' end": {"trailing", "apostrophe", "end"},
+	} {
+		if actual := Tokenize(s); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Tokenize(%q) = %q, not %q", s, actual, expected)
+		}
+	}
+}
+
+func TestStem(t *testing.T) {
+	for word, expected := range map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"ties":           "ti",
+		"caress":         "caress",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"bled":           "bled",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"conflated":      "conflat",
+		"troubled":       "troubl",
+		"sized":          "size",
+		"hopping":        "hop",
+		"tanned":         "tan",
+		"falling":        "fall",
+		"hissing":        "hiss",
+		"fizzed":         "fizz",
+		"failing":        "fail",
+		"filing":         "file",
+		"happy":          "happi",
+		"sky":            "sky",
+		"relational":     "relat",
+		"conditional":    "condit",
+		"rational":       "ration",
+		"valenci":        "valenc",
+		"digitizer":      "digit",
+		"generalization": "gener",
+		"connection":     "connect",
+		"connections":    "connect",
+		"connected":      "connect",
+		"adoption":       "adopt",
+		"controll":       "control",
+		"roll":           "roll",
+		"running":        "run",
+		"is":             "is",
+		"go":             "go",
+		"café":           "café",
+		"http2":          "http2",
+	} {
+		if actual := Stem(word); actual != expected {
+			t.Errorf("Stem(%q) = %q, not %q", word, actual, expected)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	s := "Don't  “stop” me'"
+	expected := []Word{{"dont", 0, 5}, {"stop", 10, 14}, {"me", 18, 20}}
+	if actual := Words(s); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Words(%q) = %v, not %v", s, actual, expected)
+	}
+}
+
+func TestIsStopWord(t *testing.T) {
+	for word, expected := range map[string]bool{
+		"the": true, "dont": true, "server": false, "": false,
+	} {
+		if actual := IsStopWord(word); actual != expected {
+			t.Errorf("IsStopWord(%q) = %v", word, actual)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/hblanks/speakwrite/internal/content"
+)
+
+// Data for archive.html, archive-year.html and archive-month.html. Year
+// is set only for the latter two, and Month only for the last.
+type ArchiveData struct {
+	BaseData
+	Years       []*content.Year
+	Year        *content.Year
+	Month       *content.Month
+	RelativeURL string
+	Title       string
+}
+
+var (
+	yearRegexp  = regexp.MustCompile(`^\d{4}$`)
+	monthRegexp = regexp.MustCompile(`^\d{2}$`)
+)
+
+// Returns whether the theme has a template.
+func (s *Server) hasTemplate(name string) bool {
+	return s.templates[name] != nil
+}
+
+// Serve GET /archive/ requests.
+func (s *Server) getArchive(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	data := ArchiveData{
+		Years:       s.Posts().Years,
+		RelativeURL: "/archive/",
+		Title:       "Archive",
+	}
+	s.serveArchive(w, r, "archive.html", &data)
+}
+
+// Serve GET /archive/:year/ requests.
+func (s *Server) getArchiveYear(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	posts := s.Posts()
+	var year *content.Year
+	if v := ps.ByName("year"); yearRegexp.MatchString(v) {
+		n, _ := strconv.Atoi(v)
+		year = posts.GetYear(n)
+	}
+	if year == nil {
+		http.NotFound(w, r)
+		return
+	}
+	data := ArchiveData{
+		Years:       posts.Years,
+		Year:        year,
+		RelativeURL: year.RelativeURL(),
+		Title:       strconv.Itoa(year.Year),
+	}
+	s.serveArchive(w, r, "archive-year.html", &data)
+}
+
+// Serve GET /archive/:year/:month/ requests.
+func (s *Server) getArchiveMonth(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	posts := s.Posts()
+	var month *content.Month
+	y, m := ps.ByName("year"), ps.ByName("month")
+	if yearRegexp.MatchString(y) && monthRegexp.MatchString(m) {
+		yn, _ := strconv.Atoi(y)
+		mn, _ := strconv.Atoi(m)
+		month = posts.GetMonth(yn, time.Month(mn))
+	}
+	if month == nil {
+		http.NotFound(w, r)
+		return
+	}
+	data := ArchiveData{
+		Years:       posts.Years,
+		Year:        posts.GetYear(month.Year),
+		Month:       month,
+		RelativeURL: month.RelativeURL(),
+		Title:       month.Date().Format("January 2006"),
+	}
+	s.serveArchive(w, r, "archive-month.html", &data)
+}
+
+// Renders an archive page, or 404s if the theme doesn't have its
+// template.
+func (s *Server) serveArchive(w http.ResponseWriter, r *http.Request, name string, data *ArchiveData) {
+	if !s.hasTemplate(name) {
+		http.NotFound(w, r)
+		return
+	}
+	data.BaseData = BaseData{Now: time.Now()}
+
+	t := s.GetTemplate(w, name)
+	if t == nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Printf("serveArchive: %s error %v", name, err)
+		sendError(w, http.StatusInternalServerError)
+		return
+	}
+	s.serveRendered(w, r, "text/html; charset=utf-8", s.postsModTime(), buf.Bytes())
+}
+
+// Returns the relative URLs of all archive pages the theme has templates
+// for.
+func (s *Server) archiveURLs() []string {
+	var urls []string
+	if s.hasTemplate("archive.html") {
+		urls = append(urls, "/archive/")
+	}
+	for _, y := range s.Posts().Years {
+		if s.hasTemplate("archive-year.html") {
+			urls = append(urls, y.RelativeURL())
+		}
+		if s.hasTemplate("archive-month.html") {
+			for _, m := range y.Months {
+				urls = append(urls, m.RelativeURL())
+			}
+		}
+	}
+	return urls
+}
@@ -0,0 +1,155 @@
+package web
+
+//
+// Fingerprinted static assets: each file under static/ is also served at
+// a path containing a hash of its content, so it can be cached forever.
+//
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	fingerprintLen = 12
+
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	staticCacheControl    = "public, max-age=300"
+)
+
+// Pattern for a fingerprinted name: ${BASE}.${HASH}${EXT}
+var fingerprintRegexp = regexp.MustCompile(
+	`^(.*)\.([0-9a-f]{12})(\.[^./]*)?$`)
+
+type assetHash struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+// Hashes of the files in a static directory, recomputed whenever a file
+// changes.
+type assets struct {
+	dir string
+
+	mu     sync.Mutex
+	hashes map[string]*assetHash
+}
+
+func newAssets(dir string) *assets {
+	return &assets{dir: dir, hashes: make(map[string]*assetHash)}
+}
+
+// Returns the fingerprint of the file at name (slash-separated, relative
+// to the static directory).
+func (a *assets) fingerprint(name string) (string, error) {
+	p := filepath.Join(a.dir, filepath.FromSlash(path.Clean("/"+name)))
+	st, err := os.Stat(p)
+	if err != nil {
+		return "", err
+	}
+	if st.IsDir() {
+		return "", os.ErrNotExist
+	}
+
+	a.mu.Lock()
+	h := a.hashes[name]
+	a.mu.Unlock()
+	if h != nil && h.modTime.Equal(st.ModTime()) && h.size == st.Size() {
+		return h.hash, nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sum := sha256.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	h = &assetHash{
+		modTime: st.ModTime(),
+		size:    st.Size(),
+		hash:    hex.EncodeToString(sum.Sum(nil))[:fingerprintLen],
+	}
+
+	a.mu.Lock()
+	a.hashes[name] = h
+	a.mu.Unlock()
+	return h.hash, nil
+}
+
+// Returns the fingerprinted name of a file, e.g. "css/site.0123456789ab.css"
+// for "css/site.css".
+func (a *assets) fingerprintedName(name string) (string, error) {
+	hash, err := a.fingerprint(name)
+	if err != nil {
+		return "", err
+	}
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext, nil
+}
+
+// Returns the name of the file a fingerprinted name refers to, if the
+// fingerprint matches its current content.
+func (a *assets) resolve(name string) (string, bool) {
+	m := fingerprintRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return "", false
+	}
+	orig := m[1] + m[3]
+	if hash, err := a.fingerprint(orig); err != nil || hash != m[2] {
+		return "", false
+	}
+	return orig, true
+}
+
+// Template function returning the URL of the fingerprinted version of a
+// static asset.
+func (s *Server) assetURL(name string) (string, error) {
+	fpName, err := s.assets.fingerprintedName(name)
+	if err != nil {
+		return "", err
+	}
+	return joinURL(s.PublicURL, path.Join("/static", fpName)), nil
+}
+
+// Serves static assets. Fingerprinted names, and anything under v/, are
+// cached forever; everything else only briefly.
+func (s *Server) getStatic(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	name := strings.TrimPrefix(ps.ByName("filepath"), "/")
+	cacheControl := staticCacheControl
+	if orig, ok := s.assets.resolve(name); ok {
+		name = orig
+		cacheControl = immutableCacheControl
+	} else if strings.HasPrefix(name, "v/") {
+		cacheControl = immutableCacheControl
+	}
+
+	f, err := http.Dir(s.staticDir).Open("/" + name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil || st.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", cacheControl)
+	http.ServeContent(w, r, st.Name(), st.ModTime(), f)
+}
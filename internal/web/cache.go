@@ -0,0 +1,60 @@
+package web
+
+//
+// Validators for rendered responses, so that clients and CDNs can make
+// conditional requests.
+//
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Returns a strong ETag for a response body.
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Returns the newest modification time of any file or directory under
+// dir. Directories count so that deleting a file counts as a change.
+func treeModTime(dir string) (time.Time, error) {
+	var newest time.Time
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	return newest, err
+}
+
+// Serves a fully rendered response with an ETag derived from its bytes
+// and a Last-Modified of modTime (or of the templates, if newer; or none
+// if modTime is zero). Answers conditional requests with 304 Not
+// Modified.
+func (s *Server) serveRendered(w http.ResponseWriter, r *http.Request, contentType string, modTime time.Time, body []byte) {
+	if !modTime.IsZero() && s.templatesModTime.After(modTime) {
+		modTime = s.templatesModTime
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", etag(body))
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(body))
+}
+
+// Returns the newest modification time of all posts, as of when content
+// was loaded: that of the whole content tree, which includes them. For
+// pages that list posts; a post's own page has the post's time.
+func (s *Server) postsModTime() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.contentModTime
+}
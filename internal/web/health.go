@@ -0,0 +1,51 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// The body of a /healthz response.
+type Health struct {
+	Status   string    `json:"status"`
+	Posts    int       `json:"posts"`
+	LoadedAt time.Time `json:"loaded_at"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Returns the server's health: "ok", or "error" if the last attempt to
+// load content failed. Earlier content, if any, is still served then,
+// but posts changed since may not render.
+func (s *Server) Health() Health {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h := Health{
+		Status:   "ok",
+		LoadedAt: s.loadedAt,
+	}
+	if s.posts != nil {
+		h.Posts = len(s.posts.Posts)
+	}
+	if s.loadErr != nil || s.posts == nil {
+		h.Status = "error"
+	}
+	if s.loadErr != nil {
+		h.Error = s.loadErr.Error()
+	}
+	return h
+}
+
+// Serve GET /healthz requests. Responds 503 if the last load of content
+// failed, so that it can be used as a readiness check.
+func (s *Server) getHealth(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	h := s.Health()
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if h.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(&h)
+}
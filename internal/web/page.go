@@ -0,0 +1,75 @@
+package web
+
+import (
+	"fmt"
+
+	"github.com/hblanks/speakwrite/internal/content"
+)
+
+// The default number of posts per page of the home page.
+const DefaultPageSize = 10
+
+// One page of posts, newest first, with links to its neighbors.
+type Paginator struct {
+	Page     int // Starting at 1.
+	NumPages int
+	Posts    []*content.Post
+	PrevURL  string // Relative URL of the newer page, or "".
+	NextURL  string // Relative URL of the older page, or "".
+}
+
+// Returns the relative URL of page n.
+func pageURL(n int) string {
+	if n <= 1 {
+		return "/"
+	}
+	return fmt.Sprintf("/page/%d/", n)
+}
+
+// Returns the number of pages needed for count posts. There's always at
+// least one, even if it's empty.
+func numPages(count, pageSize int) int {
+	if count == 0 {
+		return 1
+	}
+	return (count + pageSize - 1) / pageSize
+}
+
+// Returns page n of posts, or nil if there's no such page.
+func newPaginator(posts []*content.Post, pageSize, n int) *Paginator {
+	pages := numPages(len(posts), pageSize)
+	if n < 1 || n > pages {
+		return nil
+	}
+	start := (n - 1) * pageSize
+	end := start + pageSize
+	if end > len(posts) {
+		end = len(posts)
+	}
+	p := &Paginator{
+		Page:     n,
+		NumPages: pages,
+		Posts:    posts[start:end],
+	}
+	if n > 1 {
+		p.PrevURL = pageURL(n - 1)
+	}
+	if n < pages {
+		p.NextURL = pageURL(n + 1)
+	}
+	return p
+}
+
+// Returns the relative URL of page n, for numbered page links.
+func (p *Paginator) URL(n int) string {
+	return pageURL(n)
+}
+
+// Returns the numbers of all pages, for numbered page links.
+func (p *Paginator) PageNumbers() []int {
+	numbers := make([]int, p.NumPages)
+	for i := range numbers {
+		numbers[i] = i + 1
+	}
+	return numbers
+}
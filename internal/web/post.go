@@ -1,14 +1,17 @@
 package web
 
 import (
+	"bytes"
 	"html/template"
 	"log"
 	"net/http"
 	"path"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/hblanks/speakwrite/internal/content"
+	"github.com/hblanks/speakwrite/internal/images"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -16,24 +19,28 @@ type PostData struct {
 	BaseData
 	*content.Post
 	Content template.HTML
+	// Related posts, most related first.
+	Related []*content.Post
+	// Posts that link to this one with wiki links, newest first.
+	Backlinks []*content.Post
 }
 
-func (s *Server) identifyPost(filepath string) (*content.Post, string) {
+func (s *Server) identifyPost(posts *content.PostIndex, filepath string) (*content.Post, string) {
 	filepath = strings.TrimPrefix(filepath, "/")
 	part0, filepath, _:= strings.Cut(filepath, "/")
 	var part1 string
-	defer log.Printf("identifyPost: part0=%q part1=%q filepath=%q",
+	defer s.debugf("identifyPost: part0=%q part1=%q filepath=%q",
 		part0, part1, filepath)
 	
 	// Posts without a series are most common.
-	post := s.Posts.Get("", part0)
+	post := posts.Get("", part0)
 	if post != nil {
 		return post, filepath
 	}
 
 	// Else, look for post in a series.
 	part1, filepath, _ = strings.Cut(filepath, "/")
-	post = s.Posts.Get(part0, part1)
+	post = posts.Get(part0, part1)
 	if post != nil {
 		return post, filepath
 	}
@@ -41,24 +48,59 @@ func (s *Server) identifyPost(filepath string) (*content.Post, string) {
 	return nil, ""
 }
 
+// Serves a resized variant of an image in a post's directory, if name
+// is one. Returns false if it isn't.
+func (s *Server) serveImageVariant(w http.ResponseWriter, r *http.Request, fs ContentDir, name string) bool {
+	if !s.images.Enabled() {
+		return false
+	}
+	orig, width, ok := images.ParseVariantName(name)
+	if !ok || !images.IsResizable(orig) {
+		return false
+	}
+	if f, err := fs.Open(name); err == nil {
+		f.Close()
+		return false // A file by that name exists, so serve it instead.
+	}
+	f, err := fs.Open(orig)
+	if err != nil {
+		return false
+	}
+	f.Close()
+
+	variantPath, err := s.images.Variant(
+		filepath.Join(string(fs), filepath.FromSlash(path.Clean("/"+orig))), width)
+	if err != nil {
+		log.Printf("serveImageVariant: %v", err)
+		http.NotFound(w, r)
+		return true
+	}
+	http.ServeFile(w, r, variantPath)
+	return true
+}
+
 // Serve post and associated files.
 func (s *Server) getPost(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	post, extra := s.identifyPost(ps.ByName("filepath"))
+	posts := s.Posts()
+	post, extra := s.identifyPost(posts, ps.ByName("filepath"))
 	if post == nil {
 		http.NotFound(w, r)
 		return
 	}
 
 	if extra != "" {
-		log.Printf("getPost: post=%v filepath=%s", post.RelativeURL(), extra)
+		s.debugf("getPost: post=%v filepath=%s", post.RelativeURL(), extra)
 		fs := ContentDir(path.Dir(post.ContentPath))
+		if s.serveImageVariant(w, r, fs, extra) {
+			return
+		}
 		fileServer := http.FileServer(fs)
 		r.URL.Path = extra
 		fileServer.ServeHTTP(w, r)
 		return
 	}
 
-	log.Printf("getPost: post=%v", post.RelativeURL())
+	s.debugf("getPost: post=%v", post.RelativeURL())
 
 	t := s.GetTemplate(w, "post.html")
 	if t == nil {
@@ -68,16 +110,29 @@ func (s *Server) getPost(w http.ResponseWriter, r *http.Request, ps httprouter.P
 	if err != nil {
 		log.Printf("getPost: error %v", err)
 		sendError(w, http.StatusInternalServerError)
+		return
 	}
+	postContent = template.HTML(s.images.RewriteHTML(
+		[]byte(postContent), path.Dir(post.ContentPath)))
 
 	data := PostData{
 		BaseData: BaseData{
 			Now: time.Now(),
 		},
-		Post:    post,
-		Content: postContent,
+		Post:      post,
+		Content:   postContent,
+		Related:   posts.GetRelated(post),
+		Backlinks: posts.GetBacklinks(post),
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, &data); err != nil {
+		log.Printf("getPost: name=%s error %v", post.RelativeURL(), err)
+		sendError(w, http.StatusInternalServerError)
+		return
 	}
-	if err := t.Execute(w, &data); err != nil {
+	modTime, err := post.ModTime()
+	if err != nil {
 		log.Printf("getPost: name=%s error %v", post.RelativeURL(), err)
 	}
+	s.serveRendered(w, r, "text/html; charset=utf-8", modTime, buf.Bytes())
 }
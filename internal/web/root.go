@@ -1,8 +1,11 @@
 package web
 
 import (
+	"bytes"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
@@ -12,8 +15,9 @@ import (
 
 type RootData struct {
 	BaseData
-	Posts       *content.PostIndex
-	LatestURL   string // *absolute* URL
+	Posts       *content.PostIndex // All posts
+	Paginator   *Paginator         // Posts on this page
+	LatestURL   string             // *absolute* URL
 	RelativeURL string
 	Title       string
 }
@@ -35,16 +39,44 @@ type RootData struct {
 
 // Serve GET / requests.
 func (s *Server) getRoot(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	s.servePage(w, r, 1)
+}
+
+// Serve GET /page/:n/ requests.
+func (s *Server) getPage(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	n, err := strconv.Atoi(ps.ByName("n"))
+	switch {
+	case err != nil:
+		http.NotFound(w, r)
+	case n == 1:
+		http.Redirect(w, r, joinURL(s.PublicURL, pageURL(1)), http.StatusMovedPermanently)
+	default:
+		s.servePage(w, r, n)
+	}
+}
+
+// Serves page n of the home page.
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request, n int) {
+	posts := s.Posts()
+	paginator := newPaginator(posts.Posts, s.pageSize(), n)
+	if paginator == nil {
+		http.NotFound(w, r)
+		return
+	}
 	data := RootData{
 		BaseData: BaseData{
 			Now: time.Now(),
 		},
-		Posts:       s.Posts,
-		RelativeURL: "/",
+		Posts:       posts,
+		Paginator:   paginator,
+		RelativeURL: pageURL(n),
 		Title:       "",
 	}
+	if n > 1 {
+		data.Title = fmt.Sprintf("Page %d", n)
+	}
 
-	if post := s.Posts.GetLatest(); post != nil {
+	if post := posts.GetLatest(); post != nil {
 		data.LatestURL = post.RelativeURL()
 	}
 
@@ -52,7 +84,11 @@ func (s *Server) getRoot(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 	if t == nil {
 		return
 	}
-	if err := t.Execute(w, &data); err != nil {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, &data); err != nil {
 		log.Printf("getRoot: error %v", err)
+		sendError(w, http.StatusInternalServerError)
+		return
 	}
+	s.serveRendered(w, r, "text/html; charset=utf-8", s.postsModTime(), buf.Bytes())
 }
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"bytes"
 	"log"
 	"net/http"
 
@@ -10,15 +11,49 @@ import (
 )
 
 func (s *Server) getRSS(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	series := s.Posts.GetBaseSeries()
+	posts := s.Posts()
+	series := posts.GetBaseSeries()
 	if series == nil {
 		log.Printf("getRSS: base series not found")
 		sendError(w, http.StatusNotFound)
 		return
 	}
 
-	err := feed.WriteRSS(s.PublicURL, &series.SeriesMetadata, s.Posts.Posts, w)
+	var buf bytes.Buffer
+	err := feed.WriteRSS(s.PublicURL, &series.SeriesMetadata, posts.Posts, &buf)
 	if err != nil {
 		sendError(w, http.StatusInternalServerError)
+		return
+	}
+	s.serveRendered(w, r, "application/rss+xml; charset=utf-8",
+		s.postsModTime(), buf.Bytes())
+}
+
+func (s *Server) getAtom(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	posts := s.Posts()
+	series := posts.GetBaseSeries()
+	if series == nil {
+		log.Printf("getAtom: base series not found")
+		sendError(w, http.StatusNotFound)
+		return
+	}
+
+	var buf bytes.Buffer
+	err := feed.WriteAtom(s.PublicURL, &series.SeriesMetadata, posts.Posts, &buf)
+	if err != nil {
+		sendError(w, http.StatusInternalServerError)
+		return
+	}
+	s.serveRendered(w, r, "application/atom+xml; charset=utf-8",
+		s.postsModTime(), buf.Bytes())
+}
+
+func (s *Server) getSitemap(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var buf bytes.Buffer
+	if err := feed.WriteSitemap(s.PublicURL, s.Posts().Posts, &buf); err != nil {
+		sendError(w, http.StatusInternalServerError)
+		return
 	}
+	s.serveRendered(w, r, "application/xml; charset=utf-8",
+		s.postsModTime(), buf.Bytes())
 }
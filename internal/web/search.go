@@ -0,0 +1,87 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/hblanks/speakwrite/internal/content"
+	"github.com/hblanks/speakwrite/internal/search"
+)
+
+// Returns /search-index.json for posts.
+func (s *Server) newSearchIndexJSON(posts []*content.Post) ([]byte, error) {
+	docs := make([]*search.Document, 0, len(posts))
+	for _, post := range posts {
+		d, err := search.NewDocument(post, s.postURL(post))
+		if err != nil {
+			return nil, fmt.Errorf("search index: %w", err)
+		}
+		docs = append(docs, d)
+	}
+
+	format := s.opts.SearchIndex
+	if format == "" {
+		format = search.Full
+	}
+	var buf bytes.Buffer
+	if err := search.WriteJSON(&buf, docs, format); err != nil {
+		return nil, fmt.Errorf("search index: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Serve GET /search-index.json requests, from the index built when
+// content was loaded.
+func (s *Server) getSearchIndex(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	s.mu.RLock()
+	body := s.searchJSON
+	s.mu.RUnlock()
+	s.serveRendered(w, r, "application/json", s.postsModTime(), body)
+}
+
+// The most results a search returns.
+const maxSearchResults = 50
+
+type SearchData struct {
+	BaseData
+	Query       string
+	Results     []*search.Result
+	RelativeURL string
+	Title       string
+}
+
+// Serve GET /search?q= requests.
+func (s *Server) getSearch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	idx := s.searchIndex()
+	if idx == nil {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query().Get("q")
+	data := SearchData{
+		BaseData: BaseData{
+			Now: time.Now(),
+		},
+		Query:       q,
+		Results:     idx.Search(search.ParseQuery(q), maxSearchResults),
+		RelativeURL: "/search",
+		Title:       "Search",
+	}
+
+	t := s.GetTemplate(w, "search.html")
+	if t == nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, &data); err != nil {
+		log.Printf("getSearch: error %v", err)
+		sendError(w, http.StatusInternalServerError)
+		return
+	}
+	s.serveRendered(w, r, "text/html; charset=utf-8", s.postsModTime(), buf.Bytes())
+}
@@ -11,36 +11,80 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/hblanks/speakwrite/internal/content"
+	"github.com/hblanks/speakwrite/internal/images"
+	"github.com/hblanks/speakwrite/internal/metrics"
+	"github.com/hblanks/speakwrite/internal/search"
 )
 
+// Options for a Server. The zero value is the default.
+type Options struct {
+	Content content.Options
+	Images  images.Options
+	// Whether to log per-request debugging detail.
+	Debug bool
+	// Whether to serve request metrics at /metrics.
+	Metrics bool
+	// Posts per page of the home page (default: DefaultPageSize).
+	PageSize int
+	// Format of /search-index.json: search.Full (the default) or
+	// search.Inverted.
+	SearchIndex string
+}
+
 type Server struct {
 	PublicURL *url.URL
+	opts      Options
 
 	router *httprouter.Router
 
 	contentDir string
-	Posts      *content.PostIndex
+	// Guards posts, the search indexes and the load status, which Reload replaces
+	// while requests are served.
+	mu       sync.RWMutex
+	posts    *content.PostIndex
+	search   *search.Index // Only if the theme has search.html.
+	loadedAt time.Time
+	loadErr  error
+	// The body of /search-index.json.
+	searchJSON []byte
+	// The newest modification time in the content tree when loaded, for
+	// pages that list posts.
+	contentModTime time.Time
 	// Pages      content.PageIndex
 
-	templates map[string]*template.Template
+	templates        map[string]*template.Template
+	templatesModTime time.Time
+	images           *images.Processor
 
 	staticDir string
+	assets    *assets
+
+	metrics *metrics.Registry
 }
 
 // Creates (but does not run!) a server. Steps include:
-//	- load all templates
-//	- load all content
-//  - set up all routes
-func NewServer(publicURL, contentDir, themeDir string) (*Server, error) {
+//   - load all templates
+//   - load all content
+//   - set up all routes
+func NewServer(publicURL, contentDir, themeDir string, opts *Options) (*Server, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
 	s := &Server{
+		opts:       *opts,
 		router:     httprouter.New(),
 		contentDir: contentDir,
 		templates:  make(map[string]*template.Template),
+		images:     images.NewProcessor(opts.Images),
+	}
+	if opts.Metrics {
+		s.metrics = metrics.NewRegistry()
 	}
 
 	u, err := url.Parse(publicURL)
@@ -49,27 +93,82 @@ func NewServer(publicURL, contentDir, themeDir string) (*Server, error) {
 	}
 	s.PublicURL = u
 
+	s.staticDir = filepath.Join(themeDir, "static")
+	if _, err := ioutil.ReadDir(s.staticDir); err != nil {
+		return nil, err
+	}
+	s.assets = newAssets(s.staticDir)
+
 	if err := s.loadTemplates(filepath.Join(themeDir, "templates")); err != nil {
 		return nil, fmt.Errorf("loadTemplates error: %w", err)
 	}
+	if s.opts.Content.ShortcodesDir == "" {
+		s.opts.Content.ShortcodesDir = filepath.Join(themeDir, "shortcodes")
+	}
+	// Shortcodes change rendered posts as much as templates do.
+	shortcodes, _ := filepath.Glob(
+		filepath.Join(s.opts.Content.ShortcodesDir, "*.html"))
+	for _, p := range shortcodes {
+		if st, err := os.Stat(p); err == nil && st.ModTime().After(s.templatesModTime) {
+			s.templatesModTime = st.ModTime()
+		}
+	}
 	if err := s.loadContent(contentDir); err != nil {
 		return nil, fmt.Errorf("loadContent error: %w", err)
 	}
 
-	s.staticDir = filepath.Join(themeDir, "static")
-	if _, err := ioutil.ReadDir(s.staticDir); err != nil {
-		return nil, err
-	}
 	s.addHandlers()
 	return s, nil
 }
 
+// Returns the current post index.
+func (s *Server) Posts() *content.PostIndex {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.posts
+}
+
+// Returns the current search index, or nil if there's none.
+func (s *Server) searchIndex() *search.Index {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.search
+}
+
+// Reloads all content. If that fails, the server keeps serving the
+// content it had, and reports the error from /healthz.
+func (s *Server) Reload() error {
+	if err := s.loadContent(s.contentDir); err != nil {
+		return fmt.Errorf("loadContent error: %w", err)
+	}
+	return nil
+}
+
 func (s *Server) loadContent(contentDir string) error {
-	postIndex, err := content.NewPostIndex(s.contentDir)
+	postIndex, err := content.NewPostIndex(s.contentDir, &s.opts.Content)
+	var searchIndex *search.Index
+	if err == nil && s.hasTemplate("search.html") {
+		searchIndex, err = search.NewIndex(postIndex.Posts)
+	}
+	var searchJSON []byte
+	if err == nil {
+		searchJSON, err = s.newSearchIndexJSON(postIndex.Posts)
+	}
+	var contentModTime time.Time
+	if err == nil {
+		contentModTime, err = treeModTime(s.contentDir)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadErr = err
 	if err != nil {
 		return err
 	}
-	s.Posts = postIndex
+	s.posts = postIndex
+	s.search = searchIndex
+	s.searchJSON = searchJSON
+	s.loadedAt = time.Now()
+	s.contentModTime = contentModTime
 
 	// pageIndex, err := content.LoadPages(s.contentDir)
 	// if err != nil {
@@ -89,6 +188,12 @@ func (s *Server) loadTemplates(templatesDir string) error {
 	}
 	basePath := filepath.Join(templatesDir, "base.html")
 	for _, p := range paths {
+		if st, err := os.Stat(p); err != nil {
+			return err
+		} else if st.ModTime().After(s.templatesModTime) {
+			s.templatesModTime = st.ModTime()
+		}
+
 		t := template.New("base.html")
 		t.Funcs(map[string]interface{}{
 			"isoDate": func(t *time.Time) string {
@@ -97,6 +202,7 @@ func (s *Server) loadTemplates(templatesDir string) error {
 			"englishDate": func(t *time.Time) string {
 				return t.Format("January 2, 2006")
 			},
+			"asset": s.assetURL,
 		})
 		if p == basePath {
 			_, err = t.ParseFiles(p)
@@ -142,11 +248,51 @@ func (s *Server) postURL(post *content.Post) string {
 	return joinURL(s.PublicURL, post.RelativeURL()) + "/"
 }
 
+func (s *Server) pageSize() int {
+	if s.opts.PageSize > 0 {
+		return s.opts.PageSize
+	}
+	return DefaultPageSize
+}
+
+// Logs, if debug logging is on.
+func (s *Server) debugf(format string, v ...interface{}) {
+	if s.opts.Debug {
+		log.Printf(format, v...)
+	}
+}
+
+// Adds a GET route, recording metrics for it under its path pattern.
+func (s *Server) get(path string, handle httprouter.Handle) {
+	if s.metrics == nil {
+		s.router.GET(path, handle)
+		return
+	}
+	s.router.GET(path, func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		s.metrics.Instrument(path, http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				handle(w, r, ps)
+			})).ServeHTTP(w, r)
+	})
+}
+
 func (s *Server) addHandlers() {
-	s.router.GET("/", s.getRoot)
-	s.router.GET("/rss.xml", s.getRSS)
-	s.router.GET("/posts/*filepath", s.getPost)
-	s.router.ServeFiles("/static/*filepath", http.Dir(s.staticDir))
+	s.get("/", s.getRoot)
+	s.get("/page/:n/", s.getPage)
+	s.get("/archive/", s.getArchive)
+	s.get("/archive/:year/", s.getArchiveYear)
+	s.get("/archive/:year/:month/", s.getArchiveMonth)
+	s.get("/rss.xml", s.getRSS)
+	s.get("/atom.xml", s.getAtom)
+	s.get("/sitemap.xml", s.getSitemap)
+	s.get("/search-index.json", s.getSearchIndex)
+	s.get("/search", s.getSearch)
+	s.get("/posts/*filepath", s.getPost)
+	s.get("/static/*filepath", s.getStatic)
+	s.router.GET("/healthz", s.getHealth)
+	if s.metrics != nil {
+		s.router.Handler("GET", "/metrics", s.metrics)
+	}
 }
 
 func (s *Server) GetURLs() ([]string, error) {
@@ -158,8 +304,17 @@ func (s *Server) GetURLs() ([]string, error) {
 	}
 	urls = append(urls, publicURL)
 
+	// Add all pages of the home page, and the archives.
+	posts := s.Posts()
+	for n := 2; n <= numPages(len(posts.Posts), s.pageSize()); n++ {
+		urls = append(urls, joinURL(s.PublicURL, pageURL(n))+"/")
+	}
+	for _, u := range s.archiveURLs() {
+		urls = append(urls, joinURL(s.PublicURL, u)+"/")
+	}
+
 	// Find all posts and related files
-	for _, post := range s.Posts.Posts {
+	for _, post := range posts.Posts {
 		u := s.postURL(post)
 		urls = append(urls, u)
 		postDir := filepath.Dir(post.ContentPath)
@@ -184,6 +339,19 @@ func (s *Server) GetURLs() ([]string, error) {
 					return err
 				}
 				urls = append(urls, joinURL(parsedURL, rel))
+
+				// Add resized variants of images.
+				if s.images.Enabled() && images.IsResizable(path) {
+					info, err := s.images.Info(path)
+					if err != nil {
+						log.Printf("GetURLs: skipping variants: %v", err)
+						return nil
+					}
+					for _, w := range s.images.Widths(info) {
+						urls = append(urls,
+							joinURL(parsedURL, images.VariantName(rel, w)))
+					}
+				}
 				return nil
 			})
 		if err != nil {
@@ -191,8 +359,11 @@ func (s *Server) GetURLs() ([]string, error) {
 		}
 	}
 
-	// Add RSS feed.
+	// Add feeds, sitemap and search index.
 	urls = append(urls, publicURL+"rss.xml")
+	urls = append(urls, publicURL+"atom.xml")
+	urls = append(urls, publicURL+"sitemap.xml")
+	urls = append(urls, publicURL+"search-index.json")
 
 	// Find all static assets
 	err := filepath.Walk(s.staticDir,
@@ -204,6 +375,16 @@ func (s *Server) GetURLs() ([]string, error) {
 				return nil
 			}
 			urls = append(urls, s.staticURL(path))
+
+			rel, err := filepath.Rel(s.staticDir, path)
+			if err != nil {
+				return err
+			}
+			u, err := s.assetURL(filepath.ToSlash(rel))
+			if err != nil {
+				return err
+			}
+			urls = append(urls, u)
 			return nil
 		})
 	if err != nil {